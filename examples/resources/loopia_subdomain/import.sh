@@ -0,0 +1,5 @@
+# Subdomains can be imported using domain/subdomain
+terraform import loopia_subdomain.www example.com/www
+
+# Resellers prefix it with the customer number
+terraform import loopia_subdomain.www C12345:example.com/www
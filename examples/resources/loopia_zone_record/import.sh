@@ -0,0 +1,8 @@
+# Zone records can be imported by record ID using domain/subdomain/record_id
+terraform import loopia_zone_record.www example.com/www/123456
+
+# or by content using domain/subdomain/type/value
+terraform import loopia_zone_record.www example.com/www/A/192.0.2.1
+
+# Resellers prefix either form with the customer number
+terraform import loopia_zone_record.www C12345:example.com/www/123456
@@ -20,6 +20,12 @@ resource "loopia_zone_record" "something_example_com" {
     type  = "A"
     value = "192.0.2.1"
   }
+
+  # Add the record under its new name before removing the old one when
+  # domain or subdomain changes.
+  lifecycle {
+    create_before_destroy = true
+  }
 }
 
 output "record_id" {
@@ -0,0 +1,13 @@
+resource "loopia_zone_record" "www" {
+  domain    = "example.com"
+  subdomain = "www"
+  record = {
+    type  = "A"
+    value = "192.0.2.1"
+  }
+
+  # Add the replacement record before removing the old one.
+  lifecycle {
+    create_before_destroy = true
+  }
+}
@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/kolo/xmlrpc"
+)
+
+// apiLogSubsystem is the tflog subsystem that logs every Loopia API call.
+// Its level is set with TF_LOG_PROVIDER_LOOPIA_API, for example:
+//
+//	TF_LOG_PROVIDER_LOOPIA_API=TRACE terraform apply
+//
+// DEBUG logs the method, status and latency of each call, TRACE also logs
+// the parameters sent.
+const apiLogSubsystem = "loopia_api"
+
+// redactedLogValue replaces secrets in the API log.
+const redactedLogValue = "***"
+
+// apiLogFields are the keys of the fields logged for API calls, which
+// api_log_sensitive_fields may mask.
+var apiLogFields = []string{"method", "params", "attempt", "status", "latency", "throttle_wait", "error"}
+
+// apiLogMasking is what the API log masks besides the password.
+type apiLogMasking struct {
+	// values are masked wherever they occur, including in parameters.
+	values []string
+	// fields are the keys of fields whose values are masked entirely.
+	fields []string
+}
+
+// newAPILogMasking validates the api_log_sensitive_fields keys and returns
+// the masking. Empty values are ignored, since they would mask everything.
+func newAPILogMasking(values, fields []string) (apiLogMasking, error) {
+	var masking apiLogMasking
+	for _, value := range values {
+		if value != "" {
+			masking.values = append(masking.values, value)
+		}
+	}
+	for _, field := range fields {
+		if !slices.Contains(apiLogFields, field) {
+			return apiLogMasking{}, fmt.Errorf("unknown API log field %q, expected one of %s", field, strings.Join(apiLogFields, ", "))
+		}
+		masking.fields = append(masking.fields, field)
+	}
+	return masking, nil
+}
+
+// apiLogContext returns a context that logs API calls to the loopia_api
+// subsystem, with every occurrence of a sensitive value and every sensitive
+// field masked.
+func (c *loopiaClient) apiLogContext(ctx context.Context) context.Context {
+	ctx = tflog.NewSubsystem(ctx, apiLogSubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER", "LOOPIA", "API"))
+
+	if sensitive := c.sensitiveLogValues(); len(sensitive) > 0 {
+		ctx = tflog.SubsystemMaskAllFieldValuesStrings(ctx, apiLogSubsystem, sensitive...)
+		ctx = tflog.SubsystemMaskMessageStrings(ctx, apiLogSubsystem, sensitive...)
+	}
+	if len(c.logMasking.fields) > 0 {
+		ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, apiLogSubsystem, c.logMasking.fields...)
+	}
+	return ctx
+}
+
+// sensitiveLogValues returns the password and the configured sensitive
+// values.
+func (c *loopiaClient) sensitiveLogValues() []string {
+	var sensitive []string
+	if c.api != nil && c.api.Password != "" {
+		sensitive = append(sensitive, c.api.Password)
+	}
+	return append(sensitive, c.logMasking.values...)
+}
+
+// loggableParams returns the XML-RPC parameters with the password, which is
+// always the second parameter, redacted, and the sensitive values masked.
+// tflog only masks string fields, so values inside the parameter list and
+// in records are masked here.
+func loggableParams(params []interface{}, sensitive []string) []interface{} {
+	mask := func(s string) string {
+		for _, value := range sensitive {
+			s = strings.ReplaceAll(s, value, redactedLogValue)
+		}
+		return s
+	}
+
+	loggable := make([]interface{}, len(params))
+	for i, param := range params {
+		switch v := param.(type) {
+		case string:
+			loggable[i] = mask(v)
+		case loopia.Record:
+			v.Type = mask(v.Type)
+			v.Value = mask(v.Value)
+			loggable[i] = v
+		default:
+			loggable[i] = param
+		}
+	}
+	if len(loggable) > 1 {
+		loggable[1] = redactedLogValue
+	}
+	return loggable
+}
+
+// apiCallStatus summarizes the outcome of a single call for the API log.
+func apiCallStatus(reply interface{}, err error) string {
+	var statusErr *statusError
+	var httpErr *httpStatusError
+	var fault xmlrpc.FaultError
+
+	switch {
+	case err == nil:
+		if status, ok := reply.(*string); ok {
+			return *status
+		}
+		return "OK"
+	case errors.As(err, &statusErr):
+		return statusErr.Status
+	case errors.As(err, &httpErr):
+		return fmt.Sprintf("HTTP %d", httpErr.StatusCode)
+	case errors.As(err, &fault):
+		return fmt.Sprintf("FAULT %d", fault.Code)
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "CANCELED"
+	default:
+		return "ERROR"
+	}
+}
+
+// logAPIRequest logs a call that is about to be sent.
+func (c *loopiaClient) logAPIRequest(ctx context.Context, method string, params []interface{}, attempt int) {
+	tflog.SubsystemTrace(ctx, apiLogSubsystem, "Sending Loopia API request", map[string]any{
+		"method":  method,
+		"params":  loggableParams(params, c.sensitiveLogValues()),
+		"attempt": attempt,
+	})
+}
+
+// logAPIResponse logs the outcome of a call.
+func logAPIResponse(ctx context.Context, method string, reply interface{}, err error, latency, throttled time.Duration) {
+	fields := map[string]any{
+		"method":        method,
+		"status":        apiCallStatus(reply, err),
+		"latency":       latency.String(),
+		"throttle_wait": throttled.String(),
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	tflog.SubsystemDebug(ctx, apiLogSubsystem, "Received Loopia API response", fields)
+}
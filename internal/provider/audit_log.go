@@ -0,0 +1,180 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// Audit log outcomes. A pending entry is written before every call, so that
+// no change is made without a record of it, and a success or failure entry
+// after it.
+const (
+	auditOutcomePending = "pending"
+	auditOutcomeSuccess = "success"
+	auditOutcomeFailure = "failure"
+)
+
+// auditLog appends a JSON line before and after every mutating Loopia API
+// call to a file. Lines are written with a single append under a mutex, so parallel
+// resource operations, and plan and apply runs sharing the file, never
+// interleave.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// auditEntry is a single line of the audit log.
+type auditEntry struct {
+	Timestamp      string       `json:"timestamp"`
+	APIUser        string       `json:"api_user"`
+	CustomerNumber string       `json:"customer_number,omitempty"`
+	Method         string       `json:"method"`
+	Domain         string       `json:"domain"`
+	Subdomain      string       `json:"subdomain"`
+	Before         *auditRecord `json:"before"`
+	After          *auditRecord `json:"after"`
+	Outcome        string       `json:"outcome"`
+	Error          string       `json:"error,omitempty"`
+}
+
+// auditRecord is the content of a zone record in the audit log.
+type auditRecord struct {
+	RecordID int64  `json:"record_id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+func newAuditRecord(rec *loopia.Record) *auditRecord {
+	if rec == nil {
+		return nil
+	}
+	return &auditRecord{
+		RecordID: rec.ID,
+		Type:     rec.Type,
+		TTL:      rec.TTL,
+		Priority: rec.Priority,
+		Value:    rec.Value,
+	}
+}
+
+// newAuditLog returns an audit log that appends to path, and makes sure the
+// file can be written before any change is made.
+func newAuditLog(path string) (*auditLog, error) {
+	f, err := openAuditLog(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return &auditLog{path: path}, nil
+}
+
+func openAuditLog(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+}
+
+// write appends the entry to the audit log.
+func (l *auditLog) write(entry auditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := openAuditLog(l.path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// priorRecordKey is the context key for the content of a zone record before
+// it is updated or removed.
+type priorRecordKey struct{}
+
+// withPriorRecord returns a context that records the content of the zone
+// record an update or removal replaces in the audit log. The Loopia API
+// only takes the record ID, so only the caller knows it.
+func withPriorRecord(ctx context.Context, rec loopia.Record) context.Context {
+	return context.WithValue(ctx, priorRecordKey{}, rec)
+}
+
+// priorRecordFor returns the record set with withPriorRecord, if any.
+func priorRecordFor(ctx context.Context) *loopia.Record {
+	if rec, ok := ctx.Value(priorRecordKey{}).(loopia.Record); ok {
+		return &rec
+	}
+	return nil
+}
+
+// auditEntryFor returns the audit log entry of a mutating call, before its
+// outcome is known.
+func (c *loopiaClient) auditEntryFor(ctx context.Context, method, domain, subdomain string, before, after *loopia.Record) auditEntry {
+	return auditEntry{
+		Timestamp:      time.Now().UTC().Format(time.RFC3339Nano),
+		APIUser:        c.api.Username,
+		CustomerNumber: c.customerNumberFor(ctx),
+		Method:         method,
+		Domain:         domain,
+		Subdomain:      subdomain,
+		Before:         newAuditRecord(before),
+		After:          newAuditRecord(after),
+		Outcome:        auditOutcomePending,
+	}
+}
+
+// auditIntent writes the pending entry of a mutating call to the audit log,
+// when one is configured. The call must not be made if this fails.
+func (c *loopiaClient) auditIntent(entry auditEntry) error {
+	if c.audit == nil {
+		return nil
+	}
+	if err := c.audit.write(entry); err != nil {
+		return fmt.Errorf("%s refused, the audit log %s cannot be written: %w", entry.Method, c.audit.path, err)
+	}
+	return nil
+}
+
+// auditOutcome writes the outcome of a mutating call to the audit log, when
+// one is configured. A failed write is logged rather than returned, since
+// the change has already been made and its pending entry recorded.
+func (c *loopiaClient) auditOutcome(ctx context.Context, entry auditEntry, err error) {
+	if c.audit == nil {
+		return
+	}
+
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	entry.Outcome = auditOutcomeSuccess
+	if err != nil {
+		entry.Outcome = auditOutcomeFailure
+		entry.Error = err.Error()
+	}
+
+	if writeErr := c.audit.write(entry); writeErr != nil {
+		tflog.Error(ctx, "Unable to write Loopia audit log", map[string]any{
+			"path":   c.audit.path,
+			"method": entry.Method,
+			"error":  writeErr.Error(),
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/diskoteket/loopia-go"
+)
+
+func readAuditLog(t *testing.T, path string) []auditEntry {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open audit log: %s", err)
+	}
+	defer f.Close()
+
+	var entries []auditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid audit log line %q: %s", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unable to read audit log: %s", err)
+	}
+	return entries
+}
+
+func TestAuditLogConcurrentWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	audit, err := newAuditLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := audit.write(auditEntry{Method: "addZoneRecord", Subdomain: fmt.Sprintf("host-%d", i)}); err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if entries := readAuditLog(t, path); len(entries) != 50 {
+		t.Errorf("expected 50 entries, got %d", len(entries))
+	}
+}
+
+func TestNewAuditLogUnwritable(t *testing.T) {
+	if _, err := newAuditLog(filepath.Join(t.TempDir(), "missing", "audit.jsonl")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestLoopiaClientAuditsMutations(t *testing.T) {
+	status := "OK"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>%s</string></value></param></params></methodResponse>`, status)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := newAuditLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+	c.audit = audit
+
+	ctx := withCustomerNumber(context.Background(), "C1")
+	before := loopia.Record{ID: 7, Type: "A", TTL: 3600, Value: "192.0.2.1"}
+	after := loopia.Record{ID: 7, Type: "A", TTL: 300, Value: "192.0.2.2"}
+
+	if err := c.UpdateZoneRecord(withPriorRecord(ctx, before), "example.com", "www", after); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	status = "BAD_INDATA"
+	if err := c.RemoveZoneRecord(ctx, "example.com", "www", 7); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries := readAuditLog(t, path)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	// Every call is recorded as pending before it is made.
+	for i, method := range []string{"updateZoneRecord", "removeZoneRecord"} {
+		if pending := entries[2*i]; pending.Method != method || pending.Outcome != auditOutcomePending || pending.Error != "" {
+			t.Errorf("unexpected pending %s entry: %+v", method, pending)
+		}
+	}
+
+	update := entries[1]
+	if update.Method != "updateZoneRecord" || update.APIUser != "user@loopiaapi" || update.CustomerNumber != "C1" ||
+		update.Domain != "example.com" || update.Subdomain != "www" || update.Outcome != auditOutcomeSuccess {
+		t.Errorf("unexpected update entry: %+v", update)
+	}
+	if update.Before == nil || update.Before.Value != "192.0.2.1" || update.After == nil || update.After.Value != "192.0.2.2" {
+		t.Errorf("unexpected update records: before %+v, after %+v", update.Before, update.After)
+	}
+	if update.Timestamp == "" {
+		t.Error("expected a timestamp")
+	}
+
+	remove := entries[3]
+	if remove.Method != "removeZoneRecord" || remove.Outcome != auditOutcomeFailure || remove.Error == "" {
+		t.Errorf("unexpected remove entry: %+v", remove)
+	}
+	if remove.Before == nil || remove.Before.RecordID != 7 || remove.After != nil {
+		t.Errorf("unexpected remove records: before %+v, after %+v", remove.Before, remove.After)
+	}
+}
+
+func TestLoopiaClientAuditLogUnwritable(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>OK</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "audit")
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	audit, err := newAuditLog(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+	c.audit = audit
+
+	if err := c.AddSubdomain(context.Background(), "example.com", "www"); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls != 0 {
+		t.Errorf("expected no call to be made without an audit log entry, got %d", calls)
+	}
+}
+
+func TestLoopiaClientReadOnlyNotAudited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := newAuditLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: "http://127.0.0.1:0"}, nil, retryPolicy{})
+	c.audit = audit
+	c.readOnly = true
+
+	if err := c.AddSubdomain(context.Background(), "example.com", "www"); !errors.Is(err, errReadOnly) {
+		t.Fatalf("expected a read-only error, got: %v", err)
+	}
+	if entries := readAuditLog(t, path); len(entries) != 0 {
+		t.Errorf("expected no entries, got %+v", entries)
+	}
+}
@@ -0,0 +1,425 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/kolo/xmlrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// loopiaClient is the client shared by all resources and data sources.
+//
+// It wraps the Loopia API client and adds state that has to be shared
+// across the whole provider, such as the rate limiter and the per-zone
+// mutation locks. Every API call goes through call, which speaks XML-RPC
+// directly so that it can tell status answers and transport failures
+// apart. The methods below mirror the ones in loopia-go rather than
+// delegating to them.
+type loopiaClient struct {
+	api            *loopia.API
+	customerNumber string
+	httpClient     *http.Client
+	limiter        *rateLimiter
+	retry          retryPolicy
+	stats          *apiStats
+	audit          *auditLog
+	readOnly       bool
+	domains        *domainPolicy
+
+	protectedRecords []recordPattern
+	deletions        *deletionLimit
+	snapshots        *zoneSnapshots
+	logMasking       apiLogMasking
+
+	zoneLocksMu sync.Mutex
+	zoneLocks   map[string]*sync.Mutex
+}
+
+// customerNumberKey is the context key for the reseller customer number.
+type customerNumberKey struct{}
+
+// withCustomerNumber returns a context that makes calls on behalf of the
+// given reseller customer. An empty customer number keeps the provider
+// default.
+func withCustomerNumber(ctx context.Context, customerNumber string) context.Context {
+	if customerNumber == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, customerNumberKey{}, customerNumber)
+}
+
+// customerNumberFor returns the customer number calls made with ctx act on
+// behalf of, falling back to the provider default.
+func (c *loopiaClient) customerNumberFor(ctx context.Context) string {
+	if customerNumber, ok := ctx.Value(customerNumberKey{}).(string); ok {
+		return customerNumber
+	}
+	return c.customerNumber
+}
+
+// splitImportCustomerNumber splits the optional customer number prefix off
+// an import identifier, as in C12345:example.com/www. The colon cannot occur
+// in a domain name, so only the part before the first slash is checked.
+func splitImportCustomerNumber(id string) (customerNumber, rest string) {
+	head, _, _ := strings.Cut(id, "/")
+	if customerNumber, rest, ok := strings.Cut(head, ":"); ok {
+		return customerNumber, rest + id[len(head):]
+	}
+	return "", id
+}
+
+// newLoopiaClient wraps the given Loopia API client.
+func newLoopiaClient(api *loopia.API, limiter *rateLimiter, retry retryPolicy) *loopiaClient {
+	return &loopiaClient{
+		api:        api,
+		httpClient: &http.Client{},
+		limiter:    limiter,
+		retry:      retry,
+		stats:      newAPIStats(),
+		zoneLocks:  make(map[string]*sync.Mutex),
+	}
+}
+
+// lockZone serializes mutations of a single domain/subdomain zone and
+// returns the function that releases the lock.
+//
+// Terraform creates resources in parallel, and the Loopia API has no way
+// of returning the ID of a created record. Holding this lock across an
+// add-then-list sequence prevents two resources in the same zone from
+// picking up each other's records. Different zones do not block each other.
+func (c *loopiaClient) lockZone(domain, subdomain string) func() {
+	key := strings.ToLower(domain) + "/" + strings.ToLower(subdomain)
+
+	c.zoneLocksMu.Lock()
+	mu, ok := c.zoneLocks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		c.zoneLocks[key] = mu
+	}
+	c.zoneLocksMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// statusError is returned when Loopia answers a call with a status string
+// other than OK, such as AUTH_ERROR or RATE_LIMITED.
+type statusError struct {
+	Method string
+	Status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned status %s", e.Method, e.Status)
+}
+
+// httpStatusError is returned when the Loopia API endpoint answers with a
+// non-2xx HTTP status code.
+type httpStatusError struct {
+	Method     string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s failed with HTTP status %d", e.Method, e.StatusCode)
+}
+
+// call performs an XML-RPC call, retrying it according to the retry policy.
+// Errors are returned as a classified *apiError.
+func (c *loopiaClient) call(ctx context.Context, method string, args []interface{}, reply interface{}) (err error) {
+	ctx = c.apiLogContext(ctx)
+
+	ctx, span := startSpan(ctx, "loopia."+method, callSpanAttributes(method, args)...)
+	attempt := 0
+	defer func() {
+		span.SetAttributes(spanAttrRetryCount.Int(attempt))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	for ; ; attempt++ {
+		err = c.callOnce(ctx, method, args, reply, attempt+1)
+		if err == nil || attempt >= c.retry.maxRetries || !c.retry.shouldRetry(method, err) {
+			return newAPIError(method, err)
+		}
+		if isUnknownError(err) && ctx.Value(noUnknownErrorRetryKey{}) != nil {
+			return newAPIError(method, err)
+		}
+
+		c.stats.recordRetry()
+
+		wait := c.retry.backoff(attempt + 1)
+		tflog.Debug(ctx, "Retrying Loopia API call", map[string]any{
+			"method":  method,
+			"attempt": attempt + 1,
+			"wait":    wait.String(),
+			"error":   err.Error(),
+		})
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return newAPIError(method, err)
+		}
+	}
+}
+
+// callSpanAttributes returns the span attributes of an API call. Every
+// method that takes arguments takes the domain first, and most the
+// subdomain second.
+func callSpanAttributes(method string, args []interface{}) []attribute.KeyValue {
+	var domain, subdomain string
+	if len(args) > 0 {
+		domain, _ = args[0].(string)
+	}
+	if len(args) > 1 {
+		subdomain, _ = args[1].(string)
+	}
+	return append([]attribute.KeyValue{spanAttrMethod.String(method)}, zoneSpanAttributes(domain, subdomain)...)
+}
+
+// callOnce performs a single XML-RPC call once the rate limiter allows it.
+//
+// Loopia answers failed calls with a status string instead of an XML-RPC
+// fault, also for methods that normally return a list or a struct. Such
+// answers, and status answers other than OK, are returned as a
+// *statusError. Every attempt is logged to the
+// loopia_api subsystem.
+func (c *loopiaClient) callOnce(ctx context.Context, method string, args []interface{}, reply interface{}, attempt int) error {
+	var throttled time.Duration
+	if c.limiter != nil {
+		release, waited, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		throttled = waited
+	}
+
+	// Every Loopia API method takes an optional customer number right
+	// after the credentials, which resellers use to act on behalf of their
+	// customers. Loopia tells the forms apart by the number of arguments.
+	params := []interface{}{c.api.Username, c.api.Password}
+	if customerNumber := c.customerNumberFor(ctx); customerNumber != "" {
+		params = append(params, customerNumber)
+	}
+	params = append(params, args...)
+
+	c.stats.recordCall(ctx, method, throttled)
+	c.logAPIRequest(ctx, method, params, attempt)
+	start := time.Now()
+	err := c.roundTrip(ctx, method, params, reply)
+	logAPIResponse(ctx, method, reply, err, time.Since(start), throttled)
+
+	return err
+}
+
+// roundTrip sends a single XML-RPC request and decodes the answer into reply.
+func (c *loopiaClient) roundTrip(ctx context.Context, method string, params []interface{}, reply interface{}) error {
+	httpReq, err := xmlrpc.NewRequest(c.api.RPCEndpoint, method, params)
+	if err != nil {
+		return err
+	}
+
+	httpResp, err := c.httpClient.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return &httpStatusError{Method: method, StatusCode: httpResp.StatusCode}
+	}
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+
+	response := xmlrpc.Response(body)
+	if err := response.Err(); err != nil {
+		return err
+	}
+
+	// Methods that answer with a status string fail with any status other
+	// than OK. Other methods fail with any status string at all.
+	if status, isStatus := reply.(*string); isStatus {
+		if err := response.Unmarshal(status); err != nil {
+			return err
+		}
+		if *status != "OK" {
+			return &statusError{Method: method, Status: *status}
+		}
+		return nil
+	}
+
+	var status string
+	if response.Unmarshal(&status) == nil {
+		return &statusError{Method: method, Status: status}
+	}
+
+	return response.Unmarshal(reply)
+}
+
+// callStatus performs a call that answers with a status string. Any status
+// other than OK is an error, which the retry policy sees like any other.
+func (c *loopiaClient) callStatus(ctx context.Context, method string, args []interface{}) error {
+	var status string
+	return c.call(ctx, method, args, &status)
+}
+
+// callMutation performs a call that changes the zone, recording it in the
+// audit log before and after. A read-only client refuses it without
+// contacting the API, and so without an audit log entry. The before and
+// after records are the content of the changed record, if any.
+func (c *loopiaClient) callMutation(ctx context.Context, method, domain, subdomain string, before, after *loopia.Record, args []interface{}) error {
+	if c.readOnly {
+		return fmt.Errorf("%s: %w", method, errReadOnly)
+	}
+
+	entry := c.auditEntryFor(ctx, method, domain, subdomain, before, after)
+	if err := c.auditIntent(entry); err != nil {
+		return err
+	}
+
+	err := c.callStatus(ctx, method, args)
+	c.auditOutcome(ctx, entry, err)
+	return err
+}
+
+// GetCreditsAmount returns the credit balance of the account. It is the
+// cheapest authenticated call in the Loopia API.
+func (c *loopiaClient) GetCreditsAmount(ctx context.Context) (float64, error) {
+	var amount float64
+	if err := c.call(ctx, "getCreditsAmount", []interface{}{}, &amount); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// GetDomains returns all domains on the account.
+func (c *loopiaClient) GetDomains(ctx context.Context) ([]loopia.Domain, error) {
+	result := []loopia.Domain{}
+	if err := c.call(ctx, "getDomains", []interface{}{}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetDomain returns a single domain. The Loopia API lacks a getDomain
+// method, so this looks the domain up in getDomains.
+func (c *loopiaClient) GetDomain(ctx context.Context, domain string) (*loopia.Domain, error) {
+	domains, err := c.GetDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range domains {
+		if domains[i].Name == domain {
+			return &domains[i], nil
+		}
+	}
+	return nil, &apiError{
+		Kind:   apiErrorDomainNotFound,
+		Method: "getDomains",
+		Domain: domain,
+		Err:    fmt.Errorf("domain %s not found", domain),
+	}
+}
+
+// GetSubdomains returns all subdomains of a domain.
+func (c *loopiaClient) GetSubdomains(ctx context.Context, domain string) ([]loopia.Subdomain, error) {
+	result := []string{}
+	if err := c.call(ctx, "getSubdomains", []interface{}{domain}, &result); err != nil {
+		return nil, err
+	}
+
+	subdomains := make([]loopia.Subdomain, 0, len(result))
+	for _, name := range result {
+		subdomains = append(subdomains, loopia.Subdomain{Name: name})
+	}
+	return subdomains, nil
+}
+
+// AddSubdomain creates a subdomain.
+func (c *loopiaClient) AddSubdomain(ctx context.Context, domain, subdomain string) error {
+	return c.callMutation(ctx, "addSubdomain", domain, subdomain, nil, nil, []interface{}{domain, subdomain})
+}
+
+// RemoveSubdomain removes a subdomain and all of its zone records. It counts
+// towards max_deletions_per_run.
+func (c *loopiaClient) RemoveSubdomain(ctx context.Context, domain, subdomain string) error {
+	if err := c.deletions.reserve("removeSubdomain"); err != nil {
+		return err
+	}
+
+	return c.callMutation(ctx, "removeSubdomain", domain, subdomain, nil, nil, []interface{}{domain, subdomain})
+}
+
+// GetZoneRecords returns all zone records of a subdomain.
+func (c *loopiaClient) GetZoneRecords(ctx context.Context, domain, subdomain string) ([]loopia.Record, error) {
+	result := []loopia.Record{}
+	if err := c.call(ctx, "getZoneRecords", []interface{}{domain, subdomain}, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetZoneRecord returns a single zone record. The Loopia API lacks a
+// getZoneRecord method, so this looks the record up in getZoneRecords.
+func (c *loopiaClient) GetZoneRecord(ctx context.Context, domain, subdomain string, id int64) (*loopia.Record, error) {
+	records, err := c.GetZoneRecords(ctx, domain, subdomain)
+	if err != nil {
+		return nil, err
+	}
+	if rec := findRecordByID(records, id); rec != nil {
+		return rec, nil
+	}
+	return nil, fmt.Errorf("zone record %d not found in %s/%s", id, domain, subdomain)
+}
+
+// AddZoneRecord creates a zone record.
+//
+// Unlike loopia.API.AddZoneRecord this does not try to look up the ID of
+// the new record, since that lookup fails whenever Loopia normalizes the
+// value. Callers are expected to identify the record themselves.
+func (c *loopiaClient) AddZoneRecord(ctx context.Context, domain, subdomain string, record loopia.Record) error {
+	return c.callMutation(ctx, "addZoneRecord", domain, subdomain, nil, &record, []interface{}{domain, subdomain, record})
+}
+
+// UpdateZoneRecord updates the zone record with the ID of the given record.
+// The audit log takes the old content of the record from withPriorRecord.
+func (c *loopiaClient) UpdateZoneRecord(ctx context.Context, domain, subdomain string, record loopia.Record) error {
+	return c.callMutation(ctx, "updateZoneRecord", domain, subdomain, priorRecordFor(ctx), &record, []interface{}{domain, subdomain, record})
+}
+
+// RemoveZoneRecord removes a zone record. It counts towards
+// max_deletions_per_run, and the audit log takes the content of the record
+// from withPriorRecord.
+func (c *loopiaClient) RemoveZoneRecord(ctx context.Context, domain, subdomain string, id int64) error {
+	if err := c.deletions.reserve("removeZoneRecord"); err != nil {
+		return err
+	}
+
+	before := priorRecordFor(ctx)
+	if before == nil || before.ID != id {
+		before = &loopia.Record{ID: id}
+	}
+	return c.callMutation(ctx, "removeZoneRecord", domain, subdomain, before, nil, []interface{}{domain, subdomain, id})
+}
@@ -0,0 +1,479 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestLoopiaClientLockZone(t *testing.T) {
+	c := newLoopiaClient(nil, nil, retryPolicy{})
+
+	unlock := c.lockZone("example.com", "www")
+
+	// A different zone must not block.
+	done := make(chan struct{})
+	go func() {
+		c.lockZone("example.com", "mail")()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("lock on a different zone blocked")
+	}
+
+	// The same zone, in any case, must block until released.
+	acquired := make(chan struct{})
+	go func() {
+		c.lockZone("Example.COM", "WWW")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("lock on the same zone was acquired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("lock was not released")
+	}
+}
+
+func TestLoopiaClientCallRetriesRateLimited(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>RATE_LIMITED</string></value></param></params></methodResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><array><data><value><string>www</string></value></data></array></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{maxRetries: 2, maxWait: time.Millisecond})
+
+	subdomains, err := c.GetSubdomains(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+	if len(subdomains) != 1 || subdomains[0].Name != "www" {
+		t.Errorf("unexpected subdomains: %v", subdomains)
+	}
+}
+
+func TestLoopiaClientCallRetriesRateLimitedMutation(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		status := "OK"
+		if calls == 1 {
+			status = "RATE_LIMITED"
+		}
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>%s</string></value></param></params></methodResponse>`, status)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{maxRetries: 3, maxWait: time.Millisecond})
+
+	if err := c.AddSubdomain(context.Background(), "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestLoopiaClientCallStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>AUTH_ERROR</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{maxRetries: 2, maxWait: time.Millisecond})
+
+	_, err := c.GetZoneRecords(context.Background(), "example.com", "www")
+
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.Status != "AUTH_ERROR" {
+		t.Fatalf("expected AUTH_ERROR status error, got: %v", err)
+	}
+}
+
+func TestLoopiaClientCallCustomerNumber(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>OK</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+
+	params := func(values ...string) string {
+		var b strings.Builder
+		for _, v := range values {
+			fmt.Fprintf(&b, "<param><value><string>%s</string></value></param>", v)
+		}
+		return b.String()
+	}
+
+	if err := c.AddSubdomain(context.Background(), "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(body, params("user@loopiaapi", "secret", "example.com", "www")) {
+		t.Errorf("expected no customer number, got body: %s", body)
+	}
+
+	c.customerNumber = "C1"
+	if err := c.AddSubdomain(context.Background(), "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(body, params("user@loopiaapi", "secret", "C1", "example.com", "www")) {
+		t.Errorf("expected the provider customer number, got body: %s", body)
+	}
+
+	ctx := withCustomerNumber(context.Background(), "C2")
+	if err := c.AddSubdomain(ctx, "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(body, params("user@loopiaapi", "secret", "C2", "example.com", "www")) {
+		t.Errorf("expected the overridden customer number, got body: %s", body)
+	}
+}
+
+func TestLoopiaClientCallLogsRedactedParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>BAD_INDATA</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "s3cret-pw", RPCEndpoint: server.URL}, nil, retryPolicy{})
+
+	if err := c.AddSubdomain(ctx, "example.com", "www"); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatalf("unable to decode log output: %s", err)
+	}
+	if strings.Contains(output.String(), "s3cret-pw") {
+		t.Fatalf("password leaked into the log: %s", output.String())
+	}
+
+	var request, response map[string]interface{}
+	for _, entry := range entries {
+		switch entry["@message"] {
+		case "Sending Loopia API request":
+			request = entry
+		case "Received Loopia API response":
+			response = entry
+		}
+	}
+
+	if request == nil {
+		t.Fatalf("no request was logged: %v", entries)
+	}
+	if request["@module"] != "provider."+apiLogSubsystem {
+		t.Errorf("expected the %s subsystem, got: %v", apiLogSubsystem, request["@module"])
+	}
+	params, _ := request["params"].([]interface{})
+	if len(params) != 4 || params[0] != "user@loopiaapi" || params[1] != redactedLogValue || params[3] != "www" {
+		t.Errorf("unexpected params: %v", request["params"])
+	}
+
+	if response == nil {
+		t.Fatalf("no response was logged: %v", entries)
+	}
+	if response["method"] != "addSubdomain" || response["status"] != "BAD_INDATA" {
+		t.Errorf("unexpected response entry: %v", response)
+	}
+	if _, ok := response["latency"]; !ok {
+		t.Errorf("expected the latency to be logged: %v", response)
+	}
+}
+
+func TestLoopiaClientCallMasksSensitiveLogValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>OK</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	masking, err := newAPILogMasking([]string{"C12345", "token-value", ""}, []string{"latency"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "s3cret-pw", RPCEndpoint: server.URL}, nil, retryPolicy{})
+	c.logMasking = masking
+
+	ctx = withCustomerNumber(ctx, "C12345")
+	if err := c.AddZoneRecord(ctx, "example.com", "www", loopia.Record{Type: "TXT", Value: "verification=token-value"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, secret := range []string{"s3cret-pw", "C12345", "token-value"} {
+		if strings.Contains(output.String(), secret) {
+			t.Errorf("%s leaked into the log: %s", secret, output.String())
+		}
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatalf("unable to decode log output: %s", err)
+	}
+
+	var request, response map[string]interface{}
+	for _, entry := range entries {
+		switch entry["@message"] {
+		case "Sending Loopia API request":
+			request = entry
+		case "Received Loopia API response":
+			response = entry
+		}
+	}
+
+	params, _ := request["params"].([]interface{})
+	if len(params) != 6 || params[2] != redactedLogValue || params[3] != "example.com" {
+		t.Errorf("unexpected params: %v", request["params"])
+	}
+	if record, _ := params[5].(map[string]interface{}); record == nil || record["Value"] != "verification="+redactedLogValue {
+		t.Errorf("unexpected record param: %v", params[5])
+	}
+	if response["latency"] != redactedLogValue || response["status"] != "OK" {
+		t.Errorf("unexpected response entry: %v", response)
+	}
+}
+
+func TestNewAPILogMaskingUnknownField(t *testing.T) {
+	if _, err := newAPILogMasking(nil, []string{"password"}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// fakeLoopia is an in-memory stand-in for the zone record and subdomain
+// methods of the Loopia XML-RPC API. A subdomain exists as long as it has
+// an entry in records.
+type fakeLoopia struct {
+	mu sync.Mutex
+
+	records map[string][]loopia.Record // by domain/subdomain
+	nextID  int64
+
+	// store, if set, rewrites records the way Loopia normalizes them.
+	store func(loopia.Record) loopia.Record
+	// afterAdd, if set, runs after addZoneRecord, for simulating records
+	// added concurrently outside of the provider.
+	afterAdd func(f *fakeLoopia, zone string)
+
+	calls           []string
+	customerNumbers []string
+}
+
+// fakeLoopiaArgs is the number of arguments of each fake method, not
+// counting the credentials and the optional customer number.
+var fakeLoopiaArgs = map[string]int{
+	"getSubdomains":    1,
+	"addSubdomain":     2,
+	"removeSubdomain":  2,
+	"getZoneRecords":   2,
+	"addZoneRecord":    3,
+	"removeZoneRecord": 3,
+	"updateZoneRecord": 3,
+}
+
+type fakeXMLRPCValue struct {
+	String  *string `xml:"string"`
+	Int     *int64  `xml:"int"`
+	Members []struct {
+		Name  string          `xml:"name"`
+		Value fakeXMLRPCValue `xml:"value"`
+	} `xml:"struct>member"`
+	Text string `xml:",chardata"`
+}
+
+func (v fakeXMLRPCValue) str() string {
+	if v.String != nil {
+		return *v.String
+	}
+	return v.Text
+}
+
+func (v fakeXMLRPCValue) record() loopia.Record {
+	var rec loopia.Record
+	for _, m := range v.Members {
+		var n int64
+		if m.Value.Int != nil {
+			n = *m.Value.Int
+		}
+		switch m.Name {
+		case "record_id":
+			rec.ID = n
+		case "ttl":
+			rec.TTL = int(n)
+		case "priority":
+			rec.Priority = int(n)
+		case "type":
+			rec.Type = m.Value.str()
+		case "rdata":
+			rec.Value = m.Value.str()
+		}
+	}
+	return rec
+}
+
+func newFakeLoopia(t *testing.T) (*fakeLoopia, *loopiaClient) {
+	t.Helper()
+
+	f := &fakeLoopia{records: make(map[string][]loopia.Record), nextID: 100}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	return f, newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+}
+
+// addRecord adds a record to the zone, creating the subdomain if needed,
+// and returns its ID.
+func (f *fakeLoopia) addRecord(zone string, rec loopia.Record) int64 {
+	f.nextID++
+	rec.ID = f.nextID
+	f.records[zone] = append(f.records[zone], rec)
+	return rec.ID
+}
+
+func (f *fakeLoopia) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Method string            `xml:"methodName"`
+		Params []fakeXMLRPCValue `xml:"params>param>value"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&call); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n, ok := fakeLoopiaArgs[call.Method]
+	if !ok {
+		http.Error(w, "unknown method "+call.Method, http.StatusBadRequest)
+		return
+	}
+	args := call.Params[2:]
+	customerNumber := ""
+	if len(args) > n {
+		customerNumber = args[0].str()
+		args = args[1:]
+	}
+	f.calls = append(f.calls, call.Method)
+	f.customerNumbers = append(f.customerNumbers, customerNumber)
+
+	domain := args[0].str()
+	zone := ""
+	if n > 1 {
+		zone = domain + "/" + args[1].str()
+	}
+	_, zoneExists := f.records[zone]
+
+	var value string
+	switch call.Method {
+	case "getSubdomains":
+		var b strings.Builder
+		for key := range f.records {
+			if name, ok := strings.CutPrefix(key, domain+"/"); ok {
+				fmt.Fprintf(&b, "<value><string>%s</string></value>", name)
+			}
+		}
+		value = "<array><data>" + b.String() + "</data></array>"
+	case "addSubdomain":
+		if zoneExists {
+			value = "<string>DOMAIN_OCCUPIED</string>"
+			break
+		}
+		f.records[zone] = []loopia.Record{}
+		value = "<string>OK</string>"
+	case "removeSubdomain":
+		delete(f.records, zone)
+		value = "<string>OK</string>"
+	case "getZoneRecords":
+		if !zoneExists {
+			value = "<string>UNKNOWN_ERROR</string>"
+			break
+		}
+		var b strings.Builder
+		for _, rec := range f.records[zone] {
+			fmt.Fprintf(&b, "<value><struct>"+
+				"<member><name>record_id</name><value><int>%d</int></value></member>"+
+				"<member><name>type</name><value><string>%s</string></value></member>"+
+				"<member><name>ttl</name><value><int>%d</int></value></member>"+
+				"<member><name>priority</name><value><int>%d</int></value></member>"+
+				"<member><name>rdata</name><value><string>", rec.ID, rec.Type, rec.TTL, rec.Priority)
+			_ = xml.EscapeText(&b, []byte(rec.Value))
+			b.WriteString("</string></value></member></struct></value>")
+		}
+		value = "<array><data>" + b.String() + "</data></array>"
+	case "addZoneRecord":
+		rec := args[2].record()
+		if f.store != nil {
+			rec = f.store(rec)
+		}
+		f.addRecord(zone, rec)
+		if f.afterAdd != nil {
+			f.afterAdd(f, zone)
+		}
+		value = "<string>OK</string>"
+	case "updateZoneRecord":
+		rec := args[2].record()
+		for i := range f.records[zone] {
+			if f.records[zone][i].ID == rec.ID {
+				f.records[zone][i] = rec
+			}
+		}
+		value = "<string>OK</string>"
+	case "removeZoneRecord":
+		id := int64(0)
+		if args[2].Int != nil {
+			id = *args[2].Int
+		}
+		records := f.records[zone][:0]
+		for _, rec := range f.records[zone] {
+			if rec.ID != id {
+				records = append(records, rec)
+			}
+		}
+		f.records[zone] = records
+		value = "<string>OK</string>"
+	}
+
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value>%s</value></param></params></methodResponse>`, value)
+}
@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// credentialProcessTimeout bounds how long the credential process may run.
+const credentialProcessTimeout = time.Minute
+
+// credentialProcessOutput is the JSON document the credential process must
+// write to stdout.
+type credentialProcessOutput struct {
+	Username       string `json:"username"`
+	Password       string `json:"password"`
+	CustomerNumber string `json:"customer_number"`
+}
+
+// runCredentialProcess runs the command through the system shell and
+// parses the credentials it writes to stdout.
+//
+// The output holds the password, so errors never include it. That is also
+// why stderr is not included, since a misbehaving command may echo its
+// input there.
+func runCredentialProcess(ctx context.Context, command string, timeout time.Duration) (*credentialsProfile, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd.exe", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	// Children of the shell may keep stdout open after it was killed.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("credential process timed out after %s", timeout)
+		}
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("credential process exited with status %d, run it manually to see its output", exitErr.ExitCode())
+		}
+		return nil, fmt.Errorf("could not run credential process: %w", err)
+	}
+
+	var output credentialProcessOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		// json errors may quote parts of the input, so they are not wrapped.
+		return nil, errors.New(`credential process did not write a JSON document such as {"username": "...", "password": "..."} to stdout`)
+	}
+
+	if output.Password == "" {
+		return nil, errors.New("credential process output has no password")
+	}
+
+	return &credentialsProfile{
+		Username:       output.Username,
+		Password:       output.Password,
+		CustomerNumber: output.CustomerNumber,
+	}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	credentials, err := runCredentialProcess(context.Background(),
+		`echo '{"username": "user@loopiaapi", "password": "s3cret"}'`, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if credentials.Username != "user@loopiaapi" || credentials.Password != "s3cret" {
+		t.Errorf("unexpected credentials: %+v", *credentials)
+	}
+}
+
+func TestRunCredentialProcessErrors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		timeout time.Duration
+		want    string
+	}{
+		{"exit status", `echo s3cret; exit 3`, time.Minute, "exited with status 3"},
+		{"not json", `echo s3cret`, time.Minute, "did not write a JSON document"},
+		{"no password", `echo '{"username": "user@loopiaapi"}'`, time.Minute, "has no password"},
+		{"timeout", `sleep 5`, 50 * time.Millisecond, "timed out"},
+	}
+
+	for _, tt := range tests {
+		_, err := runCredentialProcess(context.Background(), tt.command, tt.timeout)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got: %v", tt.name, tt.want, err)
+			continue
+		}
+		if strings.Contains(err.Error(), "s3cret") {
+			t.Errorf("%s: error leaks the command output: %s", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultCredentialsProfile is the profile used when none is selected.
+const defaultCredentialsProfile = "default"
+
+var (
+	// errCredentialsFileNotFound is returned when there is no credentials file.
+	errCredentialsFileNotFound = errors.New("credentials file not found")
+
+	// errCredentialsProfileNotFound is returned when the credentials file
+	// has no profile with the requested name.
+	errCredentialsProfileNotFound = errors.New("credentials profile not found")
+)
+
+// credentialsProfile is a named profile in the shared credentials file.
+type credentialsProfile struct {
+	Username       string
+	Password       string
+	CustomerNumber string
+}
+
+// defaultCredentialsFilePath returns the path of the shared credentials
+// file, $XDG_CONFIG_HOME/loopia/credentials or ~/.config/loopia/credentials.
+func defaultCredentialsFilePath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "loopia", "credentials"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "loopia", "credentials"), nil
+}
+
+// loadCredentialsProfile reads a single profile from the credentials file.
+//
+// The file uses INI syntax, which also makes simple TOML files valid:
+//
+//	[default]
+//	username = "my-api-user@loopiaapi"
+//	password = "secret"
+//
+//	[customer-a]
+//	username        = "customer-a@loopiaapi"
+//	password        = "secret"
+//	customer_number = "C123456"
+//
+// Lines starting with # or ; are comments. Values may be quoted.
+func loadCredentialsProfile(path, name string) (*credentialsProfile, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errCredentialsFileNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var profile *credentialsProfile
+	section := ""
+
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed section header", path, lineNumber)
+			}
+			section = strings.Trim(strings.TrimSpace(line[1:len(line)-1]), `"`)
+			if section == name && profile == nil {
+				profile = &credentialsProfile{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		if section != name {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+
+		switch key {
+		case "username":
+			profile.Username = value
+		case "password":
+			profile.Password = value
+		case "customer_number":
+			profile.CustomerNumber = value
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q in profile %q", path, lineNumber, key, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if profile == nil {
+		return nil, fmt.Errorf("%w: %q in %s", errCredentialsProfileNotFound, name, path)
+	}
+	return profile, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadCredentialsProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	content := `# Loopia API users
+[default]
+username = "default@loopiaapi"
+password = "default-secret"
+
+; Customer A
+[customer-a]
+username        = customer-a@loopiaapi
+password        = 'quoted = secret'
+customer_number = "C123456"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := loadCredentialsProfile(path, "customer-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := credentialsProfile{Username: "customer-a@loopiaapi", Password: "quoted = secret", CustomerNumber: "C123456"}
+	if *profile != want {
+		t.Errorf("expected %+v, got %+v", want, *profile)
+	}
+
+	profile, err = loadCredentialsProfile(path, defaultCredentialsProfile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if profile.Username != "default@loopiaapi" || profile.Password != "default-secret" {
+		t.Errorf("unexpected default profile: %+v", *profile)
+	}
+
+	if _, err := loadCredentialsProfile(path, "missing"); !errors.Is(err, errCredentialsProfileNotFound) {
+		t.Errorf("expected errCredentialsProfileNotFound, got: %v", err)
+	}
+
+	if _, err := loadCredentialsProfile(filepath.Join(t.TempDir(), "missing"), "default"); !errors.Is(err, errCredentialsFileNotFound) {
+		t.Errorf("expected errCredentialsFileNotFound, got: %v", err)
+	}
+}
+
+func TestLoadCredentialsProfileUnknownKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte("[default]\nuser = someone\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadCredentialsProfile(path, "default"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/diskoteket/loopia-go"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -26,11 +25,12 @@ func NewDomainDataSource() datasource.DataSource {
 
 // DomainDataSource is the data source implementation.
 type DomainDataSource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // DomainDataSourceModel maps the data source schema data.
 type DomainDataSourceModel struct {
+	CustomerNumber  types.String `tfsdk:"customer_number"`
 	Name            types.String `tfsdk:"name"`
 	Paid            types.Bool   `tfsdk:"paid"`
 	Registered      types.Bool   `tfsdk:"registered"`
@@ -49,6 +49,10 @@ func (d *DomainDataSource) Schema(_ context.Context, _ datasource.SchemaRequest,
 	resp.Schema = schema.Schema{
 		Description: "Fetches details about a specific domain.",
 		Attributes: map[string]schema.Attribute{
+			"customer_number": schema.StringAttribute{
+				Optional:    true,
+				Description: "The Loopia customer number to read the domain for. Only applies to reseller accounts. Defaults to the provider customer_number.",
+			},
 			"name": schema.StringAttribute{
 				Required:    true,
 				Description: "The domain name to retrieve data for",
@@ -87,13 +91,19 @@ func (d *DomainDataSource) Read(ctx context.Context, req datasource.ReadRequest,
 		return
 	}
 
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_domain", "Read", zoneSpanAttributes(state.Name.ValueString(), "")...)
+	defer endSpan(span, &resp.Diagnostics)
+
 	// Get domain details from API
-	domain, err := d.client.GetDomain(state.Name.ValueString())
+	domain, err := d.client.GetDomain(ctx, state.Name.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Unable to Read Loopia Domain",
-			err.Error(),
-		)
+			fmt.Sprintf("Could not read domain %s", state.Name.ValueString()),
+			err,
+		))
 		return
 	}
 
@@ -115,11 +125,11 @@ func (d *DomainDataSource) Configure(_ context.Context, req datasource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *loopia.API, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/diskoteket/loopia-go"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -26,12 +25,13 @@ func NewDomainsDataSource() datasource.DataSource {
 
 // DomainsDataSource is the data source implementation.
 type DomainsDataSource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // DomainsDataSourceModel maps the data source schema data.
 type DomainsDataSourceModel struct {
-	Domains []domainsModel `tfsdk:"domains"`
+	CustomerNumber types.String   `tfsdk:"customer_number"`
+	Domains        []domainsModel `tfsdk:"domains"`
 }
 
 // domainsModel maps coffees schema data.
@@ -53,6 +53,10 @@ func (d *DomainsDataSource) Metadata(_ context.Context, req datasource.MetadataR
 func (d *DomainsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
+			"customer_number": schema.StringAttribute{
+				Optional:    true,
+				Description: "The Loopia customer number to read domains for. Only applies to reseller accounts. Defaults to the provider customer_number.",
+			},
 			"domains": schema.ListNestedAttribute{
 				Computed: true,
 				NestedObject: schema.NestedAttributeObject{
@@ -86,12 +90,23 @@ func (d *DomainsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest
 func (d *DomainsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
 	var state DomainsDataSourceModel
 
-	domains, err := d.client.GetDomains()
+	resp.Diagnostics.Append(req.Config.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_domains", "Read")
+	defer endSpan(span, &resp.Diagnostics)
+
+	domains, err := d.client.GetDomains(ctx)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Unable to Read Loopia Domains",
-			err.Error(),
-		)
+			"Could not read domains",
+			err,
+		))
 		return
 	}
 
@@ -124,11 +139,11 @@ func (d *DomainsDataSource) Configure(_ context.Context, req datasource.Configur
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *loopia.API, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
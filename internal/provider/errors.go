@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/kolo/xmlrpc"
+)
+
+// apiErrorKind classifies failed Loopia API calls by what the user can do
+// about them.
+type apiErrorKind int
+
+const (
+	apiErrorUnknown apiErrorKind = iota
+	apiErrorAuthentication
+	apiErrorPermissionDenied
+	apiErrorRateLimited
+	apiErrorDomainNotFound
+	apiErrorBadInput
+)
+
+// String returns a short description of the error kind.
+func (k apiErrorKind) String() string {
+	switch k {
+	case apiErrorAuthentication:
+		return "authentication failed"
+	case apiErrorPermissionDenied:
+		return "permission denied"
+	case apiErrorRateLimited:
+		return "rate limited"
+	case apiErrorDomainNotFound:
+		return "domain not found"
+	case apiErrorBadInput:
+		return "bad input"
+	default:
+		return "unknown error"
+	}
+}
+
+// apiError is a failed Loopia API call.
+type apiError struct {
+	Kind   apiErrorKind
+	Method string
+	Domain string
+	Err    error
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Err)
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+// hint returns a suggestion on how to resolve the error.
+func (e *apiError) hint() string {
+	switch e.Kind {
+	case apiErrorAuthentication:
+		return "Check the username and password of the Loopia API user. API user names end in @loopiaapi, " +
+			"and the credentials can be set in the provider configuration or with the LOOPIA_USERNAME " +
+			"and LOOPIA_PASSWORD environment variables."
+	case apiErrorPermissionDenied:
+		return fmt.Sprintf("Your API user lacks the %s permission. Grant it to the API user under "+
+			"LoopiaAPI in the Loopia customer zone.", e.Method)
+	case apiErrorRateLimited:
+		return "Loopia limits the number of API calls per minute. Lower the max_requests_per_minute provider " +
+			"setting, raise max_retries, or run Terraform with a lower -parallelism."
+	case apiErrorDomainNotFound:
+		if e.Domain != "" {
+			return fmt.Sprintf("Check that the domain %s is spelled correctly and belongs to the Loopia "+
+				"account of the API user.", e.Domain)
+		}
+		return "Check that the domain is spelled correctly and belongs to the Loopia account of the API user."
+	case apiErrorBadInput:
+		return fmt.Sprintf("Loopia rejected the values sent in the %s call. Check the domain and subdomain "+
+			"names, and the record type, value, TTL and priority.", e.Method)
+	default:
+		return "Loopia did not say why the call failed. Retry later, and if the error persists contact " +
+			"Loopia support or the provider developers."
+	}
+}
+
+// newAPIError classifies the error of a failed Loopia API call. Errors that
+// are already classified are returned unchanged.
+func newAPIError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *apiError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	kind := apiErrorUnknown
+
+	var statusErr *statusError
+	var httpErr *httpStatusError
+	var fault xmlrpc.FaultError
+
+	switch {
+	case errors.As(err, &statusErr):
+		switch statusErr.Status {
+		case "AUTH_ERROR":
+			kind = apiErrorAuthentication
+		case "RATE_LIMITED":
+			kind = apiErrorRateLimited
+		case "BAD_INDATA":
+			kind = apiErrorBadInput
+		}
+	case errors.As(err, &httpErr):
+		switch httpErr.StatusCode {
+		case 401:
+			kind = apiErrorAuthentication
+		case 403:
+			kind = apiErrorPermissionDenied
+		case 429:
+			kind = apiErrorRateLimited
+		}
+	case errors.As(err, &fault):
+		// Loopia answers calls to methods the API user has not been
+		// granted with an XML-RPC fault rather than a status string.
+		s := strings.ToLower(fault.String)
+		if strings.Contains(s, "permission") || strings.Contains(s, "access denied") ||
+			strings.Contains(s, "not allowed") || strings.Contains(s, "unauthorized") {
+			kind = apiErrorPermissionDenied
+		}
+	}
+
+	return &apiError{Kind: kind, Method: method, Err: err}
+}
+
+// apiErrorDiagnostic returns an error diagnostic for a failed Loopia API
+// call. The detail is extended with the error and, for errors returned by
+// the client or refused deletions, a hint on how to resolve it.
+func apiErrorDiagnostic(summary, detail string, err error) diag.Diagnostic {
+	var limitErr *deletionLimitError
+	if errors.As(err, &limitErr) {
+		return diag.NewErrorDiagnostic(summary, fmt.Sprintf("%s: %s.\n\n%s", detail, limitErr.Error(), limitErr.hint()))
+	}
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return diag.NewErrorDiagnostic(summary, fmt.Sprintf("%s: %s", detail, err.Error()))
+	}
+
+	return diag.NewErrorDiagnostic(
+		summary,
+		fmt.Sprintf("%s: %s.\n\n%s\n\nLoopia API error: %s", detail, apiErr.Kind, apiErr.hint(), apiErr.Err.Error()),
+	)
+}
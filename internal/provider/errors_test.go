@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kolo/xmlrpc"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want apiErrorKind
+	}{
+		{&statusError{Method: "getDomains", Status: "AUTH_ERROR"}, apiErrorAuthentication},
+		{&statusError{Method: "getDomains", Status: "RATE_LIMITED"}, apiErrorRateLimited},
+		{&statusError{Method: "addZoneRecord", Status: "BAD_INDATA"}, apiErrorBadInput},
+		{&statusError{Method: "addZoneRecord", Status: "UNKNOWN_ERROR"}, apiErrorUnknown},
+		{&httpStatusError{Method: "getDomains", StatusCode: 429}, apiErrorRateLimited},
+		{xmlrpc.FaultError{Code: 623, String: "Permission denied"}, apiErrorPermissionDenied},
+		{errors.New("connection reset"), apiErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		var apiErr *apiError
+		if !errors.As(newAPIError("addZoneRecord", tt.err), &apiErr) {
+			t.Fatalf("%v: expected an *apiError", tt.err)
+		}
+		if apiErr.Kind != tt.want {
+			t.Errorf("%v: expected %s, got %s", tt.err, tt.want, apiErr.Kind)
+		}
+		if !errors.Is(apiErr, tt.err) {
+			t.Errorf("%v: expected the original error to be wrapped", tt.err)
+		}
+	}
+}
+
+func TestAPIErrorDiagnostic(t *testing.T) {
+	err := newAPIError("addZoneRecord", xmlrpc.FaultError{Code: 623, String: "Permission denied"})
+
+	d := apiErrorDiagnostic("Error Creating Zone Record", "Could not create zone record", err)
+
+	if d.Summary() != "Error Creating Zone Record" {
+		t.Errorf("unexpected summary: %s", d.Summary())
+	}
+	if !strings.Contains(d.Detail(), "lacks the addZoneRecord permission") {
+		t.Errorf("expected a permission hint, got: %s", d.Detail())
+	}
+}
@@ -0,0 +1,348 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// testProviderConfig returns a provider configuration with the given
+// attribute values and all other attributes null.
+func testProviderConfig(t *testing.T, p provider.Provider, values map[string]tftypes.Value) tfsdk.Config {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp provider.SchemaResponse
+	p.Schema(ctx, provider.SchemaRequest{}, &schemaResp)
+
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+
+	attributes := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		if v, ok := values[name]; ok {
+			attributes[name] = v
+			continue
+		}
+		attributes[name] = tftypes.NewValue(attributeType, nil)
+	}
+
+	return tfsdk.Config{
+		Schema: schemaResp.Schema,
+		Raw:    tftypes.NewValue(objectType, attributes),
+	}
+}
+
+func TestLoopiaProviderConfigureDefersUnknownCredentials(t *testing.T) {
+	p := New("test")()
+	config := testProviderConfig(t, p, map[string]tftypes.Value{
+		"username": tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		"password": tftypes.NewValue(tftypes.String, "secret"),
+	})
+
+	var resp provider.ConfigureResponse
+	p.Configure(context.Background(), provider.ConfigureRequest{
+		Config: config,
+		ClientCapabilities: provider.ConfigureProviderClientCapabilities{
+			DeferralAllowed: true,
+		},
+	}, &resp)
+
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+	if resp.Deferred == nil || resp.Deferred.Reason != provider.DeferredReasonProviderConfigUnknown {
+		t.Fatalf("expected a deferral, got: %v", resp.Deferred)
+	}
+
+	// Without deferral support the unknown value is still an error.
+	resp = provider.ConfigureResponse{}
+	p.Configure(context.Background(), provider.ConfigureRequest{Config: config}, &resp)
+
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected an error without deferral support")
+	}
+	if resp.Deferred != nil {
+		t.Fatalf("unexpected deferral: %v", resp.Deferred)
+	}
+}
+
+func expectUnknownValueError(t *testing.T, diags diag.Diagnostics) {
+	t.Helper()
+
+	if !diags.HasError() || !strings.HasPrefix(diags.Errors()[0].Summary(), "Unknown ") {
+		t.Fatalf("expected an unknown value error, got: %v", diags)
+	}
+}
+
+// TestLoopiaProviderConfigureUnknownValues checks that attributes which
+// decide where calls go, or which guard changes, never fall back to a
+// default when their value is unknown.
+func TestLoopiaProviderConfigureUnknownValues(t *testing.T) {
+	testCases := map[string]struct {
+		value          tftypes.Value
+		expectDeferral bool
+	}{
+		"endpoint": {
+			value:          tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+			expectDeferral: true,
+		},
+		"customer_number": {
+			value:          tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+			expectDeferral: true,
+		},
+		"read_only": {
+			value: tftypes.NewValue(tftypes.Bool, tftypes.UnknownValue),
+		},
+		"max_deletions_per_run": {
+			value: tftypes.NewValue(tftypes.Number, tftypes.UnknownValue),
+		},
+		"audit_log_path": {
+			value: tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		},
+		"backup_dir": {
+			value: tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+		},
+		"api_log_sensitive_values": {
+			value: tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, tftypes.UnknownValue),
+		},
+	}
+
+	for attribute, testCase := range testCases {
+		t.Run(attribute, func(t *testing.T) {
+			p := New("test")()
+			config := testProviderConfig(t, p, map[string]tftypes.Value{
+				"username":                    tftypes.NewValue(tftypes.String, "user@loopiaapi"),
+				"password":                    tftypes.NewValue(tftypes.String, "secret"),
+				"skip_credentials_validation": tftypes.NewValue(tftypes.Bool, true),
+				attribute:                     testCase.value,
+			})
+
+			var resp provider.ConfigureResponse
+			p.Configure(context.Background(), provider.ConfigureRequest{
+				Config: config,
+				ClientCapabilities: provider.ConfigureProviderClientCapabilities{
+					DeferralAllowed: true,
+				},
+			}, &resp)
+
+			if deferred := resp.Deferred != nil; deferred != testCase.expectDeferral {
+				t.Fatalf("expected deferral %t, got: %v", testCase.expectDeferral, resp.Deferred)
+			}
+
+			if !testCase.expectDeferral {
+				expectUnknownValueError(t, resp.Diagnostics)
+				return
+			}
+
+			// Without deferral support the unknown value is an error.
+			resp = provider.ConfigureResponse{}
+			p.Configure(context.Background(), provider.ConfigureRequest{Config: config}, &resp)
+			expectUnknownValueError(t, resp.Diagnostics)
+		})
+	}
+}
+
+func TestLoopiaProviderConfigureReadOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a read-only provider must not call the API")
+	}))
+	defer server.Close()
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LOOPIA_READ_ONLY", "true")
+
+	p := New("test")()
+	config := testProviderConfig(t, p, map[string]tftypes.Value{
+		"username":                    tftypes.NewValue(tftypes.String, "user@loopiaapi"),
+		"password":                    tftypes.NewValue(tftypes.String, "secret"),
+		"endpoint":                    tftypes.NewValue(tftypes.String, server.URL),
+		"skip_credentials_validation": tftypes.NewValue(tftypes.Bool, true),
+		"read_only":                   tftypes.NewValue(tftypes.Bool, false),
+	})
+
+	var resp provider.ConfigureResponse
+	p.Configure(context.Background(), provider.ConfigureRequest{Config: config}, &resp)
+
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+	}
+
+	client, ok := resp.ResourceData.(*loopiaClient)
+	if !ok {
+		t.Fatalf("unexpected resource data: %T", resp.ResourceData)
+	}
+	if !client.readOnly {
+		t.Fatal("expected LOOPIA_READ_ONLY to enable read-only mode")
+	}
+
+	if err := client.AddSubdomain(context.Background(), "example.com", "www"); !errors.Is(err, errReadOnly) {
+		t.Errorf("expected a read-only error, got: %v", err)
+	}
+}
+
+func TestLoopiaProviderConfigureCredentialProcess(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("LOOPIA_USERNAME", "")
+	t.Setenv("LOOPIA_PASSWORD", "")
+
+	testCases := map[string]struct {
+		username, password string
+		expectError        bool
+	}{
+		"configuration-credentials": {
+			username: "user@loopiaapi",
+			password: "secret",
+		},
+		"configuration-username": {
+			username: "user@loopiaapi",
+		},
+		"no-configuration-credentials": {
+			expectError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			p := New("test")()
+			values := map[string]tftypes.Value{
+				"credential_process":          tftypes.NewValue(tftypes.String, "exit 3"),
+				"skip_credentials_validation": tftypes.NewValue(tftypes.Bool, true),
+			}
+			if testCase.username != "" {
+				values["username"] = tftypes.NewValue(tftypes.String, testCase.username)
+			}
+			if testCase.password != "" {
+				values["password"] = tftypes.NewValue(tftypes.String, testCase.password)
+			}
+
+			var resp provider.ConfigureResponse
+			p.Configure(context.Background(), provider.ConfigureRequest{Config: testProviderConfig(t, p, values)}, &resp)
+
+			// The credential process fails, so it must not have run when
+			// the configuration sets credentials.
+			failed := false
+			for _, d := range resp.Diagnostics.Errors() {
+				if d.Summary() == "Unable to Run Loopia Credential Process" {
+					failed = true
+				}
+			}
+			if failed != testCase.expectError {
+				t.Errorf("expected the credential process to run %t, got: %v", testCase.expectError, resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestLoopiaProviderConfigureValidatesCredentials(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	testCases := map[string]struct {
+		response       string
+		skipValidation bool
+		expectCalls    int
+		// expectSummary is the summary of the expected diagnostic on the
+		// username attribute, if any.
+		expectSummary  string
+		expectSeverity diag.Severity
+	}{
+		"auth-error": {
+			response:       `<params><param><value><string>AUTH_ERROR</string></value></param></params>`,
+			expectCalls:    1,
+			expectSummary:  "Invalid Loopia API Credentials",
+			expectSeverity: diag.SeverityError,
+		},
+		"permission-fault": {
+			response: `<fault><value><struct>` +
+				`<member><name>faultCode</name><value><int>623</int></value></member>` +
+				`<member><name>faultString</name><value><string>Permission denied</string></value></member>` +
+				`</struct></value></fault>`,
+			expectCalls:    1,
+			expectSummary:  "Loopia API User Lacks Permission",
+			expectSeverity: diag.SeverityWarning,
+		},
+		"skipped": {
+			response:       `<params><param><value><string>AUTH_ERROR</string></value></param></params>`,
+			skipValidation: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse>`+testCase.response+`</methodResponse>`)
+			}))
+			defer server.Close()
+
+			p := New("test")()
+			config := testProviderConfig(t, p, map[string]tftypes.Value{
+				"username":                    tftypes.NewValue(tftypes.String, "user@loopiaapi"),
+				"password":                    tftypes.NewValue(tftypes.String, "secret"),
+				"endpoint":                    tftypes.NewValue(tftypes.String, server.URL),
+				"max_retries":                 tftypes.NewValue(tftypes.Number, 0),
+				"skip_credentials_validation": tftypes.NewValue(tftypes.Bool, testCase.skipValidation),
+			})
+
+			var resp provider.ConfigureResponse
+			p.Configure(context.Background(), provider.ConfigureRequest{Config: config}, &resp)
+
+			if calls != testCase.expectCalls {
+				t.Errorf("expected %d API calls, got %d", testCase.expectCalls, calls)
+			}
+
+			if testCase.expectSummary == "" {
+				if len(resp.Diagnostics) > 0 {
+					t.Fatalf("unexpected diagnostics: %v", resp.Diagnostics)
+				}
+				return
+			}
+			if len(resp.Diagnostics) != 1 {
+				t.Fatalf("expected a single diagnostic, got: %v", resp.Diagnostics)
+			}
+			d, ok := resp.Diagnostics[0].(diag.DiagnosticWithPath)
+			if !ok || d.Severity() != testCase.expectSeverity || d.Summary() != testCase.expectSummary || !d.Path().Equal(path.Root("username")) {
+				t.Errorf("expected %s %q on username, got: %v", testCase.expectSeverity, testCase.expectSummary, resp.Diagnostics[0])
+			}
+		})
+	}
+}
+
+func TestSelectCredentials(t *testing.T) {
+	config := credentialSource{"config", credentialsProfile{Username: "config@loopiaapi"}}
+	env := credentialSource{"env", credentialsProfile{Username: "env@loopiaapi", Password: "env-secret"}}
+	empty := credentialSource{"empty", credentialsProfile{}}
+
+	// A username in the configuration is never paired with the password
+	// of another source.
+	if got := selectCredentials(empty, config, env); got.name != "config" || got.Password != "" {
+		t.Errorf("expected the configuration without a password, got %+v", got)
+	}
+
+	if got := selectCredentials(empty, env); got.name != "env" || got.Username != "env@loopiaapi" || got.Password != "env-secret" {
+		t.Errorf("expected the environment, got %+v", got)
+	}
+
+	if got := selectCredentials(empty); got.name != "" {
+		t.Errorf("expected no source, got %+v", got)
+	}
+}
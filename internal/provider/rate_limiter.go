@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultMaxRequestsPerMinute matches the call quota Loopia publishes
+	// for its API users.
+	defaultMaxRequestsPerMinute = 60
+
+	// defaultMaxConcurrentRequests limits the number of API calls in flight.
+	defaultMaxConcurrentRequests = 4
+)
+
+// rateLimiter is a token bucket limiter combined with a concurrency limit.
+//
+// The bucket holds a tenth of the per-minute quota and is refilled with
+// the remainder over a minute, so no sliding one minute window can ever
+// see more calls than the quota allows.
+type rateLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	burst    float64
+	interval time.Duration
+	last     time.Time
+
+	sem chan struct{}
+}
+
+// newRateLimiter creates a limiter allowing requestsPerMinute calls per
+// minute with at most maxConcurrent calls in flight.
+func newRateLimiter(requestsPerMinute, maxConcurrent int) *rateLimiter {
+	burst := requestsPerMinute / 10
+	if burst < 1 {
+		burst = 1
+	}
+	refill := requestsPerMinute - burst
+	if refill < 1 {
+		refill = 1
+	}
+
+	return &rateLimiter{
+		tokens:   float64(burst),
+		burst:    float64(burst),
+		interval: time.Minute / time.Duration(refill),
+		last:     time.Now(),
+		sem:      make(chan struct{}, maxConcurrent),
+	}
+}
+
+// acquire blocks until a call may be made. It returns the function that
+// must be called once the call has completed, and how long it waited.
+func (l *rateLimiter) acquire(ctx context.Context) (func(), time.Duration, error) {
+	start := time.Now()
+
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, time.Since(start), ctx.Err()
+	}
+	release := func() { <-l.sem }
+
+	if wait := l.reserve(); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			l.cancel()
+			release()
+			return nil, time.Since(start), ctx.Err()
+		}
+	}
+
+	return release, time.Since(start), nil
+}
+
+// reserve takes a token from the bucket and returns how long the caller has
+// to wait before the token is actually available.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(l.interval))
+}
+
+// cancel returns a reserved token that was never used.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+}
@@ -0,0 +1,49 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := newRateLimiter(60, 1)
+
+	// The bucket starts with a tenth of the quota.
+	for i := 0; i < 6; i++ {
+		if wait := l.reserve(); wait != 0 {
+			t.Fatalf("reserve %d: expected no wait, got %s", i, wait)
+		}
+	}
+
+	// The rest of the quota is spread over the minute.
+	wait := l.reserve()
+	if wait <= 0 || wait > l.interval {
+		t.Fatalf("expected a wait of at most %s, got %s", l.interval, wait)
+	}
+}
+
+func TestRateLimiterAcquireCanceled(t *testing.T) {
+	l := newRateLimiter(1, 1)
+
+	release, _, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, _, err := l.acquire(ctx); err == nil {
+		t.Fatal("expected the second call to be throttled until the context expired")
+	}
+
+	// The canceled reservation must be handed back.
+	if l.tokens < -1e-3 {
+		t.Fatalf("expected the token to be returned, bucket holds %f", l.tokens)
+	}
+}
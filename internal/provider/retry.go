@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMaxRetries is the number of times a failed call is retried.
+	defaultMaxRetries = 3
+
+	// defaultRetryMaxWait caps the backoff between two attempts.
+	defaultRetryMaxWait = 30 * time.Second
+
+	// retryBaseWait is the backoff before the first retry.
+	retryBaseWait = time.Second
+)
+
+// retryPolicy decides which failed calls are retried and for how long.
+type retryPolicy struct {
+	maxRetries int
+	maxWait    time.Duration
+}
+
+type noUnknownErrorRetryKey struct{}
+
+// withoutUnknownErrorRetry returns a context whose calls are not retried when
+// Loopia answers UNKNOWN_ERROR, for callers that can tell a lasting cause,
+// such as a removed subdomain, apart themselves.
+func withoutUnknownErrorRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noUnknownErrorRetryKey{}, true)
+}
+
+// isUnknownError reports whether err is an UNKNOWN_ERROR answer.
+func isUnknownError(err error) bool {
+	var statusErr *statusError
+	return errors.As(err, &statusErr) && statusErr.Status == "UNKNOWN_ERROR"
+}
+
+// isReadMethod reports whether the Loopia API method only reads data and
+// can therefore be repeated without side effects.
+func isReadMethod(method string) bool {
+	return strings.HasPrefix(method, "get")
+}
+
+// shouldRetry reports whether a call that failed with err may be repeated.
+//
+// Calls that were rejected before Loopia acted on them are always safe to
+// retry. Anything else is only retried for read methods, since a mutating
+// call might already have taken effect.
+func (p retryPolicy) shouldRetry(method string, err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Status {
+		case "RATE_LIMITED":
+			return true
+		case "UNKNOWN_ERROR":
+			return isReadMethod(method)
+		default:
+			return false
+		}
+	}
+
+	var httpErr *httpStatusError
+	if errors.As(err, &httpErr) {
+		switch {
+		case httpErr.StatusCode == 429:
+			return true
+		case httpErr.StatusCode >= 500:
+			return isReadMethod(method)
+		default:
+			return false
+		}
+	}
+
+	// The request never left the machine, so nothing can have changed.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+
+	// Dropped connections and other transport failures.
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, net.ErrClosed) {
+		return isReadMethod(method)
+	}
+
+	return false
+}
+
+// backoff returns the jittered wait before the given retry attempt,
+// starting at 1 for the first retry.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	if p.maxWait <= 0 {
+		return 0
+	}
+
+	wait := retryBaseWait << (attempt - 1)
+	if wait <= 0 || wait > p.maxWait {
+		wait = p.maxWait
+	}
+
+	// Full jitter spreads out parallel resources that failed together.
+	return time.Duration(rand.Int63n(int64(wait)) + 1)
+}
@@ -0,0 +1,52 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	p := retryPolicy{maxRetries: 3, maxWait: time.Second}
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"rate limited read", "getZoneRecords", &statusError{Status: "RATE_LIMITED"}, true},
+		{"rate limited write", "addZoneRecord", &statusError{Status: "RATE_LIMITED"}, true},
+		{"unknown error read", "getZoneRecords", &statusError{Status: "UNKNOWN_ERROR"}, true},
+		{"unknown error write", "addZoneRecord", &statusError{Status: "UNKNOWN_ERROR"}, false},
+		{"auth error", "getDomains", &statusError{Status: "AUTH_ERROR"}, false},
+		{"http 503 read", "getSubdomains", &httpStatusError{StatusCode: 503}, true},
+		{"http 503 write", "removeZoneRecord", &httpStatusError{StatusCode: 503}, false},
+		{"http 429 write", "removeZoneRecord", &httpStatusError{StatusCode: 429}, true},
+		{"dial error write", "addSubdomain", &net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{"reset read", "getDomains", &net.OpError{Op: "read", Err: errors.New("reset")}, true},
+		{"reset write", "updateZoneRecord", &net.OpError{Op: "read", Err: errors.New("reset")}, false},
+		{"canceled", "getDomains", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		if got := p.shouldRetry(tt.method, tt.err); got != tt.want {
+			t.Errorf("%s: expected %t, got %t", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := retryPolicy{maxRetries: 10, maxWait: 5 * time.Second}
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		if wait := p.backoff(attempt); wait <= 0 || wait > p.maxWait {
+			t.Errorf("attempt %d: wait %s outside (0, %s]", attempt, wait, p.maxWait)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	fwpath "github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// errReadOnly is returned for mutating calls made by a read-only provider.
+var errReadOnly = errors.New("the provider is read-only")
+
+// readOnlyDiagnostic returns the error diagnostic of a change refused
+// because the provider is read-only. The action is the verb of the change,
+// such as "created".
+func readOnlyDiagnostic(resourceType, action string) diag.Diagnostic {
+	return diag.NewErrorDiagnostic(
+		"Loopia Provider Is Read-Only",
+		fmt.Sprintf("The provider is configured with read_only or the LOOPIA_READ_ONLY environment variable, so "+
+			"%s resources cannot be %s. Reads and data sources keep working. Unset both to allow changes.", resourceType, action),
+	)
+}
+
+// domainPolicy limits the domains the provider may manage resources in,
+// with glob patterns such as "example.com" or "*.example.se".
+type domainPolicy struct {
+	allowed []string
+	denied  []string
+}
+
+// newDomainPolicy validates the patterns and returns the policy, or nil if
+// neither list has any patterns.
+func newDomainPolicy(allowed, denied []string) (*domainPolicy, error) {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+
+	policy := &domainPolicy{}
+	for _, list := range []struct {
+		name     string
+		patterns []string
+		target   *[]string
+	}{
+		{"allowed_domains", allowed, &policy.allowed},
+		{"denied_domains", denied, &policy.denied},
+	} {
+		for _, pattern := range list.patterns {
+			pattern = strings.ToLower(pattern)
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid %s pattern %q: %w", list.name, pattern, err)
+			}
+			*list.target = append(*list.target, pattern)
+		}
+	}
+	return policy, nil
+}
+
+// check returns an error if the provider may not manage resources in the
+// domain. Denied patterns take precedence over allowed ones.
+func (p *domainPolicy) check(domain string) error {
+	if p == nil {
+		return nil
+	}
+
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	for _, pattern := range p.denied {
+		if matched, _ := path.Match(pattern, domain); matched {
+			return fmt.Errorf("domain %s matches the denied_domains pattern %q", domain, pattern)
+		}
+	}
+
+	if len(p.allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range p.allowed {
+		if matched, _ := path.Match(pattern, domain); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %s does not match any allowed_domains pattern", domain)
+}
+
+// modifyPlanDomainPolicy rejects plans that create, change or destroy
+// resources in a domain the provider may not manage. The domain is read
+// from both the prior state and the plan, since a replacement touches both.
+// Resources that the plan leaves as they are pass, so that denying a domain
+// does not fail every plan until its resources are removed.
+func modifyPlanDomainPolicy(ctx context.Context, client *loopiaClient, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if client == nil || client.domains == nil {
+		return
+	}
+
+	if !req.State.Raw.IsNull() && !req.Plan.Raw.IsNull() && req.Plan.Raw.Equal(req.State.Raw) {
+		return
+	}
+
+	var domains []types.String
+	if !req.State.Raw.IsNull() {
+		var domain types.String
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, fwpath.Root("domain"), &domain)...)
+		domains = append(domains, domain)
+	}
+	if !req.Plan.Raw.IsNull() {
+		var domain types.String
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, fwpath.Root("domain"), &domain)...)
+		domains = append(domains, domain)
+	}
+
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		if domain.IsNull() || domain.IsUnknown() || seen[domain.ValueString()] {
+			continue
+		}
+		seen[domain.ValueString()] = true
+
+		if err := client.domains.check(domain.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				fwpath.Root("domain"),
+				"Domain Not Managed by This Provider",
+				fmt.Sprintf("The provider is not allowed to manage resources in this domain: %s. "+
+					"Check the domain for typos, or change allowed_domains or denied_domains in the provider configuration.", err),
+			)
+		}
+	}
+}
+
+// recordPattern is a protected_records pattern of the form
+// domain/subdomain/type, such as "*/@/MX". Each part is a glob pattern.
+type recordPattern struct {
+	raw       string
+	domain    string
+	subdomain string
+	rtype     string
+}
+
+// parseRecordPatterns parses and validates protected_records patterns.
+func parseRecordPatterns(patterns []string) ([]recordPattern, error) {
+	parsed := make([]recordPattern, 0, len(patterns))
+	for _, raw := range patterns {
+		parts := strings.Split(strings.ToLower(raw), "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid protected_records pattern %q: expected domain/subdomain/type, such as */@/MX", raw)
+		}
+		for _, part := range parts {
+			if _, err := path.Match(part, ""); err != nil {
+				return nil, fmt.Errorf("invalid protected_records pattern %q: %w", raw, err)
+			}
+		}
+		parsed = append(parsed, recordPattern{raw: raw, domain: parts[0], subdomain: parts[1], rtype: parts[2]})
+	}
+	return parsed, nil
+}
+
+// globMatch reports whether the lower-cased value matches the glob pattern.
+func globMatch(pattern, value string) bool {
+	matched, _ := path.Match(pattern, strings.ToLower(value))
+	return matched
+}
+
+// matchesZone reports whether the pattern matches records of some type in
+// the zone.
+func (p recordPattern) matchesZone(domain, subdomain string) bool {
+	return globMatch(p.domain, strings.TrimSuffix(domain, ".")) && globMatch(p.subdomain, subdomain)
+}
+
+// matches reports whether the pattern matches records of the given type in
+// the zone.
+func (p recordPattern) matches(domain, subdomain, recordType string) bool {
+	return p.matchesZone(domain, subdomain) && globMatch(p.rtype, recordType)
+}
+
+// checkDeletionProtection returns an error diagnostic if the resource may
+// not be deleted, either because its deletion_protection attribute is set
+// or because it matches a protected_records pattern. The record type is
+// empty for subdomains, whose records checkProtectedRecords checks.
+func (c *loopiaClient) checkDeletionProtection(resourceType string, deletionProtection bool, domain, subdomain, recordType string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	name := domain + "/" + subdomain
+	if recordType != "" {
+		name += " " + recordType + " record"
+	}
+
+	if deletionProtection {
+		diags.AddAttributeError(
+			fwpath.Root("deletion_protection"),
+			"Resource Is Protected From Deletion",
+			fmt.Sprintf("The %s %s has deletion_protection set, so it cannot be deleted or replaced. "+
+				"If the deletion is intended, set deletion_protection to false and apply that change first.", resourceType, name),
+		)
+		return diags
+	}
+
+	if recordType == "" {
+		return diags
+	}
+
+	for _, pattern := range c.protectedRecords {
+		if pattern.matches(domain, subdomain, recordType) {
+			diags.AddError(
+				"Resource Is Protected From Deletion",
+				fmt.Sprintf("The %s %s matches the protected_records pattern %q in the provider configuration, so it "+
+					"cannot be deleted or replaced. If the deletion is intended, remove the pattern from protected_records first.",
+					resourceType, name, pattern.raw),
+			)
+			return diags
+		}
+	}
+
+	return diags
+}
+
+// checkProtectedRecords returns an error diagnostic if the subdomain holds a
+// record that matches a protected_records pattern, since removing the
+// subdomain removes its records too. The zone is only listed when a pattern
+// could match it.
+func (c *loopiaClient) checkProtectedRecords(ctx context.Context, domain, subdomain string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var patterns []recordPattern
+	for _, pattern := range c.protectedRecords {
+		if pattern.matchesZone(domain, subdomain) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return diags
+	}
+
+	records, err := c.GetZoneRecords(ctx, domain, subdomain)
+	if err != nil {
+		diags.Append(apiErrorDiagnostic(
+			"Unable to Check Protected Records",
+			"Could not list the zone records of the subdomain to check them against protected_records, so it was not deleted",
+			err,
+		))
+		return diags
+	}
+
+	for _, rec := range records {
+		for _, pattern := range patterns {
+			if globMatch(pattern.rtype, rec.Type) {
+				diags.AddError(
+					"Resource Is Protected From Deletion",
+					fmt.Sprintf("The loopia_subdomain %s/%s holds the %s record %d, which matches the protected_records pattern %q "+
+						"in the provider configuration, so it cannot be deleted or replaced. If the deletion is intended, delete "+
+						"the record or remove the pattern from protected_records first.", domain, subdomain, rec.Type, rec.ID, pattern.raw),
+				)
+				return diags
+			}
+		}
+	}
+
+	return diags
+}
+
+// deletionLimit is the max_deletions_per_run circuit breaker. It counts the
+// zone record and subdomain removals of a single provider process, which is
+// a single plan or apply, and refuses removals past the limit.
+type deletionLimit struct {
+	mu    sync.Mutex
+	limit int
+	count int
+}
+
+func newDeletionLimit(limit int) *deletionLimit {
+	return &deletionLimit{limit: limit}
+}
+
+// reserve counts a removal, or returns a *deletionLimitError if the limit
+// has been reached. Removals that fail still count, since Loopia may have
+// acted on them. A nil limit allows any number of removals.
+func (l *deletionLimit) reserve(method string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count >= l.limit {
+		return &deletionLimitError{Method: method, Limit: l.limit}
+	}
+	l.count++
+	return nil
+}
+
+// deletionLimitError is returned for removals refused by max_deletions_per_run.
+type deletionLimitError struct {
+	Method string
+	Limit  int
+}
+
+func (e *deletionLimitError) Error() string {
+	return fmt.Sprintf("%s refused, the provider has already made the %d deletions max_deletions_per_run allows in a single run", e.Method, e.Limit)
+}
+
+// hint returns how to make an intentional large change.
+func (e *deletionLimitError) hint() string {
+	return "If this many deletions are intended, raise max_deletions_per_run in the provider configuration, or set the " +
+		"LOOPIA_MAX_DELETIONS_PER_RUN environment variable for a single run, for example " +
+		"LOOPIA_MAX_DELETIONS_PER_RUN=200 terraform apply. Resources whose deletion was refused are kept in the state."
+}
@@ -0,0 +1,279 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// testResourceValue returns a value of the resource schema with the given
+// attribute values and all other attributes null, or a null value if
+// values is nil.
+func testResourceValue(t *testing.T, r resource.Resource, values map[string]tftypes.Value) tftypes.Value {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	objectType := schemaResp.Schema.Type().TerraformType(ctx).(tftypes.Object)
+	if values == nil {
+		return tftypes.NewValue(objectType, nil)
+	}
+
+	attributes := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		if v, ok := values[name]; ok {
+			attributes[name] = v
+			continue
+		}
+		attributes[name] = tftypes.NewValue(attributeType, nil)
+	}
+	return tftypes.NewValue(objectType, attributes)
+}
+
+// testModifyPlan runs ModifyPlan of the resource for a change from the prior
+// state to the plan, either of which may be nil.
+func testModifyPlan(t *testing.T, r resource.ResourceWithModifyPlan, state, plan map[string]tftypes.Value) *resource.ModifyPlanResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	planValue := testResourceValue(t, r, plan)
+	req := resource.ModifyPlanRequest{
+		Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: planValue},
+		Plan:   tfsdk.Plan{Schema: schemaResp.Schema, Raw: planValue},
+		State:  tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, state)},
+	}
+	resp := &resource.ModifyPlanResponse{
+		Plan: req.Plan,
+	}
+
+	r.ModifyPlan(ctx, req, resp)
+	return resp
+}
+
+func TestDomainPolicyCheck(t *testing.T) {
+	policy, err := newDomainPolicy([]string{"example.com", "*.SE"}, []string{"customer-*.se"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := map[string]bool{
+		"example.com":     true,
+		"Example.COM.":    true,
+		"example.se":      true,
+		"example.net":     false,
+		"sub.example.com": false,
+		"customer-a.se":   false,
+	}
+
+	for domain, allowed := range testCases {
+		t.Run(domain, func(t *testing.T) {
+			err := policy.check(domain)
+			if allowed && err != nil {
+				t.Errorf("expected %s to be allowed, got: %s", domain, err)
+			}
+			if !allowed && err == nil {
+				t.Errorf("expected %s to be rejected", domain)
+			}
+		})
+	}
+}
+
+func TestNewDomainPolicy(t *testing.T) {
+	if policy, err := newDomainPolicy(nil, nil); policy != nil || err != nil {
+		t.Errorf("expected no policy, got: %v, %v", policy, err)
+	}
+	if _, err := newDomainPolicy([]string{"[example.com"}, nil); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+
+	policy, err := newDomainPolicy(nil, []string{"example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := policy.check("example.net"); err != nil {
+		t.Errorf("expected domains that are not denied to be allowed, got: %s", err)
+	}
+}
+
+func TestModifyPlanDomainPolicy(t *testing.T) {
+	policy, err := newDomainPolicy([]string{"example.com"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r := &subdomainResource{client: &loopiaClient{domains: policy}}
+
+	value := func(domain string) map[string]tftypes.Value {
+		return map[string]tftypes.Value{
+			"domain":    tftypes.NewValue(tftypes.String, domain),
+			"subdomain": tftypes.NewValue(tftypes.String, "www"),
+		}
+	}
+
+	testCases := map[string]struct {
+		state, plan map[string]tftypes.Value
+		expectError bool
+	}{
+		"create-allowed": {
+			plan: value("example.com"),
+		},
+		"create-denied": {
+			plan:        value("example.net"),
+			expectError: true,
+		},
+		"destroy-denied": {
+			state:       value("example.net"),
+			expectError: true,
+		},
+		"unchanged-denied": {
+			state: value("example.net"),
+			plan:  value("example.net"),
+		},
+		"replace-from-denied": {
+			state:       value("example.net"),
+			plan:        value("example.com"),
+			expectError: true,
+		},
+		"unknown": {
+			plan: map[string]tftypes.Value{
+				"domain": tftypes.NewValue(tftypes.String, tftypes.UnknownValue),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resp := testModifyPlan(t, r, testCase.state, testCase.plan)
+			if resp.Diagnostics.HasError() != testCase.expectError {
+				t.Errorf("expected error %t, got: %v", testCase.expectError, resp.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestParseRecordPatterns(t *testing.T) {
+	for _, pattern := range []string{"*/@", "*/@/MX/extra", "[*/@/MX"} {
+		if _, err := parseRecordPatterns([]string{pattern}); err == nil {
+			t.Errorf("expected an error for %q", pattern)
+		}
+	}
+}
+
+func TestCheckDeletionProtection(t *testing.T) {
+	patterns, err := parseRecordPatterns([]string{"*/@/MX", "example.com/@/NS"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c := &loopiaClient{protectedRecords: patterns}
+
+	testCases := map[string]struct {
+		deletionProtection bool
+		domain             string
+		subdomain          string
+		recordType         string
+		expectError        bool
+	}{
+		"unprotected": {
+			domain: "example.com", subdomain: "www", recordType: "A",
+		},
+		"attribute": {
+			deletionProtection: true,
+			domain:             "example.com", subdomain: "www", recordType: "A",
+			expectError: true,
+		},
+		"apex-mx": {
+			domain: "example.net", subdomain: "@", recordType: "mx",
+			expectError: true,
+		},
+		"apex-ns-other-domain": {
+			domain: "example.net", subdomain: "@", recordType: "NS",
+		},
+		"apex-ns": {
+			domain: "Example.com", subdomain: "@", recordType: "NS",
+			expectError: true,
+		},
+		// Subdomains are checked against their actual records by
+		// checkProtectedRecords.
+		"subdomain-with-protected-records": {
+			domain: "example.net", subdomain: "@",
+		},
+		"subdomain": {
+			domain: "example.net", subdomain: "www",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			diags := c.checkDeletionProtection("loopia_zone_record", testCase.deletionProtection, testCase.domain, testCase.subdomain, testCase.recordType)
+			if diags.HasError() != testCase.expectError {
+				t.Errorf("expected error %t, got: %v", testCase.expectError, diags)
+			}
+		})
+	}
+}
+
+func TestLoopiaClientDeletionLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>OK</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{})
+	c.deletions = newDeletionLimit(2)
+
+	if err := c.RemoveZoneRecord(context.Background(), "example.com", "www", 1); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := c.RemoveSubdomain(context.Background(), "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err := c.RemoveZoneRecord(context.Background(), "example.com", "mail", 2)
+
+	var limitErr *deletionLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != 2 {
+		t.Fatalf("expected a deletion limit error, got: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+
+	// Other changes are not limited.
+	if err := c.AddSubdomain(context.Background(), "example.com", "mail"); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	d := apiErrorDiagnostic("Error Deleting Zone Record", "Could not delete zone record ID 2", err)
+	if !strings.Contains(d.Detail(), "LOOPIA_MAX_DELETIONS_PER_RUN") {
+		t.Errorf("expected the diagnostic to explain how to raise the limit, got: %s", d.Detail())
+	}
+}
+
+func TestLoopiaClientNoDeletionLimit(t *testing.T) {
+	var limit *deletionLimit
+	for i := 0; i < 100; i++ {
+		if err := limit.reserve("removeZoneRecord"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
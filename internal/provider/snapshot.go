@@ -0,0 +1,132 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// zoneSnapshots saves the records of a zone before the provider deletes or
+// rewrites any of them, since Loopia keeps no history. Each run writes to
+// its own directory under backup_dir, with one file per domain/subdomain
+// holding the zone as it was before the first destructive change of the
+// run.
+type zoneSnapshots struct {
+	dir string
+
+	mu    sync.Mutex
+	taken map[string]bool
+}
+
+// zoneSnapshot is the content of a snapshot file.
+type zoneSnapshot struct {
+	TakenAt        string         `json:"taken_at"`
+	Reason         string         `json:"reason"`
+	Domain         string         `json:"domain"`
+	Subdomain      string         `json:"subdomain"`
+	CustomerNumber string         `json:"customer_number,omitempty"`
+	Records        []*auditRecord `json:"records"`
+}
+
+// newZoneSnapshots returns the snapshots of a run started at the given
+// time, and makes sure backupDir exists before any change is made. The
+// directory of the run is only created with its first snapshot, so plans
+// leave none behind.
+func newZoneSnapshots(backupDir string, started time.Time) (*zoneSnapshots, error) {
+	if err := os.MkdirAll(backupDir, 0o700); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(backupDir, "loopia-"+started.UTC().Format("20060102T150405Z"))
+	return &zoneSnapshots{dir: dir, taken: make(map[string]bool)}, nil
+}
+
+// snapshotFileName returns the file name of a zone snapshot. Characters
+// that are not safe in file names on every platform, such as the * of a
+// wildcard subdomain, are percent-encoded.
+func snapshotFileName(domain, subdomain string) string {
+	escape := func(s string) string {
+		var b strings.Builder
+		for _, r := range strings.ToLower(s) {
+			switch {
+			case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '-', r == '_', r == '@':
+				b.WriteRune(r)
+			default:
+				fmt.Fprintf(&b, "%%%02X", r)
+			}
+		}
+		return b.String()
+	}
+	return escape(domain) + "_" + escape(subdomain) + ".json"
+}
+
+// snapshotZone saves the records of the zone, unless they have already
+// been saved during this run. The caller must hold the zone lock, which
+// also keeps other snapshots of the zone out. The reason, such as
+// "delete loopia_zone_record", is saved with the records.
+func (c *loopiaClient) snapshotZone(ctx context.Context, domain, subdomain, reason string) error {
+	s := c.snapshots
+	if s == nil {
+		return nil
+	}
+
+	name := snapshotFileName(domain, subdomain)
+
+	s.mu.Lock()
+	taken := s.taken[name]
+	s.mu.Unlock()
+	if taken {
+		return nil
+	}
+
+	records, err := c.GetZoneRecords(ctx, domain, subdomain)
+	if err != nil {
+		return err
+	}
+
+	snapshot := zoneSnapshot{
+		TakenAt:        time.Now().UTC().Format(time.RFC3339),
+		Reason:         reason,
+		Domain:         domain,
+		Subdomain:      subdomain,
+		CustomerNumber: c.customerNumberFor(ctx),
+		Records:        make([]*auditRecord, 0, len(records)),
+	}
+	for i := range records {
+		snapshot.Records = append(snapshot.Records, newAuditRecord(&records[i]))
+	}
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, name)
+	if err := os.WriteFile(path, append(b, '\n'), 0o600); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.taken[name] = true
+	s.mu.Unlock()
+
+	tflog.Info(ctx, "Saved Loopia zone snapshot", map[string]any{
+		"domain":    domain,
+		"subdomain": subdomain,
+		"path":      path,
+		"records":   len(records),
+	})
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+)
+
+func TestSnapshotFileName(t *testing.T) {
+	tests := map[string][2]string{
+		"example.com_www.json":   {"example.com", "www"},
+		"example.com_@.json":     {"Example.com", "@"},
+		"example.com_%2A.json":   {"example.com", "*"},
+		"example.com_%2A.a.json": {"example.com", "*.a"},
+	}
+
+	for want, zone := range tests {
+		if got := snapshotFileName(zone[0], zone[1]); got != want {
+			t.Errorf("snapshotFileName(%q, %q) = %q, want %q", zone[0], zone[1], got, want)
+		}
+	}
+}
+
+func TestLoopiaClientSnapshotZone(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><array><data>`+
+			`<value><struct>`+
+			`<member><name>record_id</name><value><int>7</int></value></member>`+
+			`<member><name>type</name><value><string>A</string></value></member>`+
+			`<member><name>ttl</name><value><int>3600</int></value></member>`+
+			`<member><name>priority</name><value><int>0</int></value></member>`+
+			`<member><name>rdata</name><value><string>192.0.2.1</string></value></member>`+
+			`</struct></value>`+
+			`</data></array></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	started := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	snapshots, err := newZoneSnapshots(backupDir, started)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if entries, err := os.ReadDir(backupDir); err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty backup directory, got %v, %v", entries, err)
+	}
+
+	c := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+	c.snapshots = snapshots
+
+	ctx := withCustomerNumber(context.Background(), "C1")
+	for i := 0; i < 2; i++ {
+		if err := c.snapshotZone(ctx, "example.com", "*", "delete loopia_zone_record"); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected the zone to be read once per run, got %d calls", n)
+	}
+
+	if filepath.Base(snapshots.dir) != "loopia-20240501T123000Z" {
+		t.Errorf("unexpected run directory %s", snapshots.dir)
+	}
+
+	b, err := os.ReadFile(filepath.Join(snapshots.dir, "example.com_%2A.json"))
+	if err != nil {
+		t.Fatalf("unable to read snapshot: %s", err)
+	}
+
+	var snapshot zoneSnapshot
+	if err := json.Unmarshal(b, &snapshot); err != nil {
+		t.Fatalf("invalid snapshot: %s", err)
+	}
+	if snapshot.Domain != "example.com" || snapshot.Subdomain != "*" || snapshot.CustomerNumber != "C1" ||
+		snapshot.Reason != "delete loopia_zone_record" {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+	if len(snapshot.Records) != 1 || snapshot.Records[0].RecordID != 7 || snapshot.Records[0].Value != "192.0.2.1" {
+		t.Errorf("unexpected snapshot records: %+v", snapshot.Records)
+	}
+}
+
+func TestLoopiaClientSnapshotZoneDisabled(t *testing.T) {
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: "http://127.0.0.1:0"}, nil, retryPolicy{})
+	if err := c.snapshotZone(context.Background(), "example.com", "www", "delete loopia_subdomain"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// providerResourceType is the resource type that calls made by the provider
+// itself, such as the credential validation, are counted under.
+const providerResourceType = "provider"
+
+// resourceTypeKey is the context key for the resource or data source type
+// that API calls are made for.
+type resourceTypeKey struct{}
+
+// withResourceType returns a context whose API calls are counted under the
+// given resource or data source type.
+func withResourceType(ctx context.Context, resourceType string) context.Context {
+	return context.WithValue(ctx, resourceTypeKey{}, resourceType)
+}
+
+// resourceTypeFor returns the resource type the calls made with ctx are
+// counted under.
+func resourceTypeFor(ctx context.Context) string {
+	if resourceType, ok := ctx.Value(resourceTypeKey{}).(string); ok {
+		return resourceType
+	}
+	return providerResourceType
+}
+
+// apiStats counts the Loopia API calls made during a single run of the
+// provider. Loopia rate limits API users, so these show which parts of a
+// configuration are expensive.
+type apiStats struct {
+	mu sync.Mutex
+
+	calls           map[string]int
+	resourceCalls   map[string]int
+	retries         int
+	throttledCalls  int
+	throttleWaitSum time.Duration
+}
+
+// apiStatsSummary is the summary of the API call stats, as written to the
+// stats file.
+type apiStatsSummary struct {
+	Timestamp           string         `json:"timestamp"`
+	PID                 int            `json:"pid"`
+	Calls               int            `json:"calls"`
+	CallsByMethod       map[string]int `json:"calls_by_method"`
+	CallsByResourceType map[string]int `json:"calls_by_resource_type"`
+	Retries             int            `json:"retries"`
+	ThrottledCalls      int            `json:"throttled_calls"`
+	ThrottleWaitSeconds float64        `json:"throttle_wait_seconds"`
+
+	throttleWait time.Duration
+}
+
+func newAPIStats() *apiStats {
+	return &apiStats{
+		calls:         make(map[string]int),
+		resourceCalls: make(map[string]int),
+	}
+}
+
+// recordCall counts a single attempt of an API call, and how long the rate
+// limiter held it back.
+func (s *apiStats) recordCall(ctx context.Context, method string, throttled time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+	s.resourceCalls[resourceTypeFor(ctx)]++
+	if throttled > 0 {
+		s.throttledCalls++
+		s.throttleWaitSum += throttled
+	}
+}
+
+// recordRetry counts a retried API call.
+func (s *apiStats) recordRetry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retries++
+}
+
+// summary returns a copy of the stats.
+func (s *apiStats) summary() apiStatsSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := apiStatsSummary{
+		Timestamp:           time.Now().UTC().Format(time.RFC3339Nano),
+		PID:                 os.Getpid(),
+		CallsByMethod:       make(map[string]int, len(s.calls)),
+		CallsByResourceType: make(map[string]int, len(s.resourceCalls)),
+		Retries:             s.retries,
+		ThrottledCalls:      s.throttledCalls,
+		ThrottleWaitSeconds: s.throttleWaitSum.Seconds(),
+		throttleWait:        s.throttleWaitSum,
+	}
+	for method, n := range s.calls {
+		summary.CallsByMethod[method] = n
+		summary.Calls += n
+	}
+	for resourceType, n := range s.resourceCalls {
+		summary.CallsByResourceType[resourceType] = n
+	}
+	return summary
+}
+
+// appendFile appends the summary as a JSON line to path. Terraform starts a
+// separate provider process for each plan, apply and refresh, so each
+// process appends its own line rather than overwriting the others. Lines
+// are written with a single append, which keeps processes sharing the file
+// from interleaving.
+func (s apiStatsSummary) appendFile(path string) error {
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+)
+
+func TestLoopiaClientCallStats(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>RATE_LIMITED</string></value></param></params></methodResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><array><data></data></array></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{maxRetries: 2, maxWait: time.Millisecond})
+
+	ctx := withResourceType(context.Background(), "loopia_zone_record")
+	if _, err := c.GetZoneRecords(ctx, "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.GetSubdomains(context.Background(), "example.com"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	c.stats.recordCall(context.Background(), "getDomains", 1500*time.Millisecond)
+
+	summary := c.stats.summary()
+
+	if summary.Calls != 4 {
+		t.Errorf("expected 4 calls, got %d", summary.Calls)
+	}
+	if summary.CallsByMethod["getZoneRecords"] != 2 || summary.CallsByMethod["getSubdomains"] != 1 {
+		t.Errorf("unexpected calls by method: %v", summary.CallsByMethod)
+	}
+	if summary.CallsByResourceType["loopia_zone_record"] != 2 || summary.CallsByResourceType[providerResourceType] != 2 {
+		t.Errorf("unexpected calls by resource type: %v", summary.CallsByResourceType)
+	}
+	if summary.Retries != 1 {
+		t.Errorf("expected 1 retry, got %d", summary.Retries)
+	}
+	if summary.ThrottledCalls != 1 || summary.ThrottleWaitSeconds != 1.5 {
+		t.Errorf("unexpected throttling: %d calls, %f seconds", summary.ThrottledCalls, summary.ThrottleWaitSeconds)
+	}
+}
+
+func TestLoopiaProviderCloseAppendsStatsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+
+	// Each process, such as those of a plan and of an apply, appends its own
+	// summary.
+	for _, method := range []string{"getSubdomains", "addSubdomain"} {
+		stats := newAPIStats()
+		stats.recordCall(withResourceType(context.Background(), "loopia_subdomain"), method, 0)
+
+		p := &LoopiaProvider{stats: stats, statsFile: path, statsCtx: context.Background()}
+		if err := p.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read stats file: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got: %s", b)
+	}
+
+	var summary apiStatsSummary
+	if err := json.Unmarshal([]byte(lines[1]), &summary); err != nil {
+		t.Fatalf("stats line is not valid JSON: %s", err)
+	}
+	if summary.Calls != 1 || summary.CallsByMethod["addSubdomain"] != 1 || summary.CallsByResourceType["loopia_subdomain"] != 1 {
+		t.Errorf("unexpected stats: %s", lines[1])
+	}
+	if summary.Timestamp == "" || summary.PID != os.Getpid() {
+		t.Errorf("expected the timestamp and process ID, got: %s", lines[1])
+	}
+}
@@ -6,8 +6,9 @@ package provider
 import (
 	"context"
 	"fmt"
+	"strings"
 
-	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
@@ -17,8 +18,10 @@ import (
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ resource.Resource              = &subdomainResource{}
-	_ resource.ResourceWithConfigure = &subdomainResource{}
+	_ resource.Resource                = &subdomainResource{}
+	_ resource.ResourceWithConfigure   = &subdomainResource{}
+	_ resource.ResourceWithImportState = &subdomainResource{}
+	_ resource.ResourceWithModifyPlan  = &subdomainResource{}
 )
 
 // NewSubdomainResource is a helper function to simplify the provider implementation.
@@ -28,13 +31,16 @@ func NewSubdomainResource() resource.Resource {
 
 // subdomainResource is the resource implementation.
 type subdomainResource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // SubdomainsDataSourceModel maps the data source schema data.
 type SubdomainResourceModel struct {
 	Domain    types.String `tfsdk:"domain"`
 	Subdomain types.String `tfsdk:"subdomain"`
+
+	CustomerNumber     types.String `tfsdk:"customer_number"`
+	DeletionProtection types.Bool   `tfsdk:"deletion_protection"`
 }
 
 // Metadata returns the resource type name.
@@ -53,6 +59,17 @@ func (r *subdomainResource) Schema(_ context.Context, _ resource.SchemaRequest,
 					stringplanmodifier.RequiresReplace(),
 				},
 			},
+			"customer_number": schema.StringAttribute{
+				Description: "The Loopia customer number to manage the subdomain on behalf of. Only applies to reseller accounts. Defaults to the provider customer_number. Changing this replaces the subdomain.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"deletion_protection": schema.BoolAttribute{
+				Description: "Refuse to delete or replace the subdomain, and with it all of its records, while set. Defaults to false.",
+				Optional:    true,
+			},
 			"subdomain": schema.StringAttribute{
 				Description: "The subdomain to create",
 				Required:    true,
@@ -66,6 +83,11 @@ func (r *subdomainResource) Schema(_ context.Context, _ resource.SchemaRequest,
 
 // Create creates the resource and sets the initial Terraform state.
 func (r *subdomainResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_subdomain", "created"))
+		return
+	}
+
 	// Retrieve values from Plan
 	var plan SubdomainResourceModel
 
@@ -77,13 +99,22 @@ func (r *subdomainResource) Create(ctx context.Context, req resource.CreateReque
 
 	// We do not need to generate a request because we got everyhting we need...
 
+	ctx = withCustomerNumber(ctx, plan.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_subdomain", "Create", zoneSpanAttributes(plan.Domain.ValueString(), plan.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	unlock := r.client.lockZone(plan.Domain.ValueString(), plan.Subdomain.ValueString())
+	defer unlock()
+
 	// Get domain details from API
-	_, err := r.client.AddSubdomain(plan.Domain.ValueString(), plan.Subdomain.ValueString())
+	err := r.client.AddSubdomain(ctx, plan.Domain.ValueString(), plan.Subdomain.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error creating subdomain",
-			"Could not create subdomain, unexpected error: "+err.Error(),
-		)
+			"Could not create subdomain",
+			err,
+		))
 		return
 	}
 
@@ -110,13 +141,19 @@ func (r *subdomainResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_subdomain", "Read", zoneSpanAttributes(state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
 	// Fetch all subdomains for the domain
-	subdomains, err := r.client.GetSubdomains(state.Domain.ValueString())
+	subdomains, err := r.client.GetSubdomains(ctx, state.Domain.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Unable to Read Subdomains",
-			err.Error(),
-		)
+			fmt.Sprintf("Could not read subdomains of %s", state.Domain.ValueString()),
+			err,
+		))
 		return
 	}
 
@@ -141,13 +178,36 @@ func (r *subdomainResource) Read(ctx context.Context, req resource.ReadRequest,
 
 // Update updates the resource and sets the updated Terraform state on success.
 //
-// Since the Loopia API lacks an update method for subdomains we trigger recreation on all changes to the attributes.
-// This is just a placeholder.
+// Since the Loopia API lacks an update method for subdomains we trigger recreation on all changes to the
+// domain and subdomain. The remaining attributes only affect the provider, so the plan is saved as-is.
 func (r *subdomainResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_subdomain", "updated"))
+		return
+	}
+
+	var plan SubdomainResourceModel
+
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, span := startOperation(ctx, "loopia_subdomain", "Update", zoneSpanAttributes(plan.Domain.ValueString(), plan.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
 }
 
 // Delete deletes the resource and removes the Terraform state on success.
 func (r *subdomainResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_subdomain", "deleted"))
+		return
+	}
+
 	var state SubdomainResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -156,13 +216,47 @@ func (r *subdomainResource) Delete(ctx context.Context, req resource.DeleteReque
 		return
 	}
 
+	resp.Diagnostics.Append(r.client.checkDeletionProtection(
+		"loopia_subdomain",
+		state.DeletionProtection.ValueBool(),
+		state.Domain.ValueString(),
+		state.Subdomain.ValueString(),
+		"",
+	)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_subdomain", "Delete", zoneSpanAttributes(state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	unlock := r.client.lockZone(state.Domain.ValueString(), state.Subdomain.ValueString())
+	defer unlock()
+
+	resp.Diagnostics.Append(r.client.checkProtectedRecords(ctx, state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.client.snapshotZone(ctx, state.Domain.ValueString(), state.Subdomain.ValueString(), "delete loopia_subdomain"); err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostic(
+			"Unable to Snapshot Loopia Zone",
+			"Could not save the zone records to backup_dir before deleting the subdomain, so it was not deleted",
+			err,
+		))
+		return
+	}
+
 	// Delete existing subdomain
-	_, err := r.client.RemoveSubDomain(state.Domain.ValueString(), state.Subdomain.ValueString())
+	err := r.client.RemoveSubdomain(ctx, state.Domain.ValueString(), state.Subdomain.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error Deleting Loopia Subdomain",
-			"Could not delete subdomain, unexpected error: "+err.Error(),
-		)
+			"Could not delete subdomain",
+			err,
+		))
 		return
 	}
 }
@@ -175,12 +269,12 @@ func (r *subdomainResource) Configure(_ context.Context, req resource.ConfigureR
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *hashicups.Client, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
@@ -188,3 +282,29 @@ func (r *subdomainResource) Configure(_ context.Context, req resource.ConfigureR
 
 	r.client = client
 }
+
+// ModifyPlan rejects changes in domains the provider may not manage.
+func (r *subdomainResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	modifyPlanDomainPolicy(ctx, r.client, req, resp)
+}
+
+// ImportState imports an existing subdomain using an ID of the form
+// domain/subdomain, optionally prefixed with a reseller customer number, as
+// in C12345:example.com/www.
+func (r *subdomainResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	customerNumber, id := splitImportCustomerNumber(req.ID)
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected import identifier with format: [customer_number:]domain/subdomain. Got: %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("domain"), parts[0])...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("subdomain"), parts[1])...)
+	if customerNumber != "" {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("customer_number"), customerNumber)...)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestSubdomainResourceRequiresReplace(t *testing.T) {
+	testRequiresReplace(t, &subdomainResource{}, "domain", "subdomain", "customer_number")
+}
+
+func TestSubdomainResourceReadRemoved(t *testing.T) {
+	f, client := newFakeLoopia(t)
+	f.records["example.com/www"] = []loopia.Record{}
+
+	r := &subdomainResource{client: client}
+
+	for subdomain, expectRemoved := range map[string]bool{"www": false, "gone": true} {
+		t.Run(subdomain, func(t *testing.T) {
+			resp := testRead(t, r, map[string]tftypes.Value{
+				"domain":    tftypes.NewValue(tftypes.String, "example.com"),
+				"subdomain": tftypes.NewValue(tftypes.String, subdomain),
+			})
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+			if removed := resp.State.Raw.IsNull(); removed != expectRemoved {
+				t.Errorf("expected removed %t, got %t", expectRemoved, removed)
+			}
+		})
+	}
+}
+
+// testDelete runs Delete of the resource with the given prior state.
+func testDelete(t *testing.T, r resource.Resource, state map[string]tftypes.Value) *resource.DeleteResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	req := resource.DeleteRequest{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, state)},
+	}
+	resp := &resource.DeleteResponse{State: req.State}
+	r.Delete(ctx, req, resp)
+	return resp
+}
+
+func TestSubdomainResourceDeleteProtectedRecords(t *testing.T) {
+	patterns, err := parseRecordPatterns([]string{"*/@/MX"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	testCases := map[string]struct {
+		subdomain   string
+		recordType  string
+		expectError bool
+	}{
+		"protected-record": {
+			subdomain: "@", recordType: "MX",
+			expectError: true,
+		},
+		"other-record": {
+			subdomain: "@", recordType: "A",
+		},
+		"other-subdomain": {
+			subdomain: "www", recordType: "MX",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, client := newFakeLoopia(t)
+			client.protectedRecords = patterns
+			zone := "example.com/" + testCase.subdomain
+			f.addRecord(zone, loopia.Record{Type: testCase.recordType, TTL: 3600, Value: "192.0.2.1"})
+
+			r := &subdomainResource{client: client}
+			resp := testDelete(t, r, map[string]tftypes.Value{
+				"domain":    tftypes.NewValue(tftypes.String, "example.com"),
+				"subdomain": tftypes.NewValue(tftypes.String, testCase.subdomain),
+			})
+			if resp.Diagnostics.HasError() != testCase.expectError {
+				t.Fatalf("expected error %t, got: %v", testCase.expectError, resp.Diagnostics)
+			}
+			if _, kept := f.records[zone]; kept != testCase.expectError {
+				t.Errorf("expected subdomain kept %t, got %t", testCase.expectError, kept)
+			}
+		})
+	}
+}
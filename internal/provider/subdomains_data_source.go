@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/diskoteket/loopia-go"
 	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
@@ -27,13 +26,14 @@ func NewSubdomainsDataSource() datasource.DataSource {
 
 // SubdomainsDataSource is the data source implementation.
 type SubdomainsDataSource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // SubdomainsDataSourceModel maps the data source schema data.
 type SubdomainsDataSourceModel struct {
-	Domain     types.String `tfsdk:"domain"`
-	Subdomains types.List   `tfsdk:"subdomains"`
+	CustomerNumber types.String `tfsdk:"customer_number"`
+	Domain         types.String `tfsdk:"domain"`
+	Subdomains     types.List   `tfsdk:"subdomains"`
 }
 
 // Metadata returns the data source type name.
@@ -45,6 +45,10 @@ func (d *SubdomainsDataSource) Metadata(_ context.Context, req datasource.Metada
 func (d *SubdomainsDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Attributes: map[string]schema.Attribute{
+			"customer_number": schema.StringAttribute{
+				Optional:    true,
+				Description: "The Loopia customer number to read subdomains for. Only applies to reseller accounts. Defaults to the provider customer_number.",
+			},
 			"domain": schema.StringAttribute{
 				Required:    true,
 				Description: "The domain name to retrieve subdomains for",
@@ -68,12 +72,18 @@ func (d *SubdomainsDataSource) Read(ctx context.Context, req datasource.ReadRequ
 		return
 	}
 
-	subdomains, err := d.client.GetSubdomains(state.Domain.ValueString())
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_subdomains", "Read", zoneSpanAttributes(state.Domain.ValueString(), "")...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	subdomains, err := d.client.GetSubdomains(ctx, state.Domain.ValueString())
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Unable to Read Loopia Subdomains",
-			err.Error(),
-		)
+			fmt.Sprintf("Could not read subdomains of %s", state.Domain.ValueString()),
+			err,
+		))
 		return
 	}
 
@@ -108,11 +118,11 @@ func (d *SubdomainsDataSource) Configure(_ context.Context, req datasource.Confi
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *loopia.API, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 
 		return
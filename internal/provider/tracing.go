@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the provider spans.
+const tracerName = "github.com/hashicorp/terraform-provider-loopia/internal/provider"
+
+// Span attribute keys.
+const (
+	spanAttrDomain     = attribute.Key("loopia.domain")
+	spanAttrSubdomain  = attribute.Key("loopia.subdomain")
+	spanAttrMethod     = attribute.Key("rpc.method")
+	spanAttrRetryCount = attribute.Key("loopia.retry_count")
+)
+
+// StartTracing exports spans over OTLP when the standard OpenTelemetry
+// environment variables ask for it, that is when OTEL_EXPORTER_OTLP_ENDPOINT
+// or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set, or OTEL_TRACES_EXPORTER is
+// otlp. The exporter itself is configured with the other OTEL_EXPORTER_OTLP_*
+// variables, and OTEL_EXPORTER_OTLP_PROTOCOL selects grpc or http/protobuf.
+//
+// The returned function flushes pending spans and must be called before the
+// provider exits. Tracing is a no-op when it is not enabled.
+func StartTracing(ctx context.Context, version string) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	if !tracingEnabled() {
+		return noop, nil
+	}
+
+	var client otlptrace.Client
+	switch tracingProtocol() {
+	case "grpc":
+		client = otlptracegrpc.NewClient()
+	default:
+		client = otlptracehttp.NewClient()
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return noop, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over
+	// the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "terraform-provider-loopia"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return noop, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// tracingEnabled reports whether the environment enables OTLP trace export.
+func tracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+
+	if exporters := os.Getenv("OTEL_TRACES_EXPORTER"); exporters != "" {
+		for _, exporter := range strings.Split(exporters, ",") {
+			if strings.TrimSpace(exporter) == "otlp" {
+				return true
+			}
+		}
+		return false
+	}
+
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// tracingProtocol returns the OTLP protocol selected in the environment.
+func tracingProtocol() string {
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+}
+
+// startSpan starts a span, such as "loopia_zone_record.Create" or
+// "loopia.getZoneRecords".
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startOperation starts the span of a resource or data source operation,
+// and counts the API calls made with the returned context under the
+// resource type.
+func startOperation(ctx context.Context, resourceType, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return startSpan(withResourceType(ctx, resourceType), resourceType+"."+operation, attrs...)
+}
+
+// zoneSpanAttributes returns the span attributes of a domain/subdomain zone.
+// Empty values are left out.
+func zoneSpanAttributes(domain, subdomain string) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if domain != "" {
+		attrs = append(attrs, spanAttrDomain.String(domain))
+	}
+	if subdomain != "" {
+		attrs = append(attrs, spanAttrSubdomain.String(subdomain))
+	}
+	return attrs
+}
+
+// endSpan ends the span of an operation started with startOperation, and
+// marks it as failed when the operation returned error diagnostics.
+func endSpan(span trace.Span, diags *diag.Diagnostics) {
+	if diags.HasError() {
+		errs := diags.Errors()
+		span.SetStatus(codes.Error, errs[0].Summary())
+	}
+	span.End()
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingEnabled(t *testing.T) {
+	testCases := map[string]struct {
+		env      map[string]string
+		expected bool
+	}{
+		"unset": {
+			expected: false,
+		},
+		"endpoint": {
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"},
+			expected: true,
+		},
+		"traces-endpoint": {
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://localhost:4318/v1/traces"},
+			expected: true,
+		},
+		"exporter-otlp": {
+			env:      map[string]string{"OTEL_TRACES_EXPORTER": "console, otlp"},
+			expected: true,
+		},
+		"exporter-none": {
+			env:      map[string]string{"OTEL_TRACES_EXPORTER": "none", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"},
+			expected: false,
+		},
+		"sdk-disabled": {
+			env:      map[string]string{"OTEL_SDK_DISABLED": "true", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://localhost:4318"},
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			for _, key := range []string{"OTEL_SDK_DISABLED", "OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+				t.Setenv(key, testCase.env[key])
+			}
+
+			if got := tracingEnabled(); got != testCase.expected {
+				t.Errorf("expected %t, got %t", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoopiaClientCallSpan(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>RATE_LIMITED</string></value></param></params></methodResponse>`)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><array><data></data></array></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	c := newLoopiaClient(&loopia.API{RPCEndpoint: server.URL}, nil, retryPolicy{maxRetries: 2, maxWait: time.Millisecond})
+
+	ctx, span := startSpan(context.Background(), "loopia_zone_records.Read")
+	if _, err := c.GetZoneRecords(ctx, "example.com", "www"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	call := spans[0]
+	if call.Name() != "loopia.getZoneRecords" {
+		t.Errorf("unexpected span name: %s", call.Name())
+	}
+	if call.Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("expected the API call span to be a child of the operation span")
+	}
+
+	expected := map[attribute.Key]attribute.Value{
+		spanAttrMethod:     attribute.StringValue("getZoneRecords"),
+		spanAttrDomain:     attribute.StringValue("example.com"),
+		spanAttrSubdomain:  attribute.StringValue("www"),
+		spanAttrRetryCount: attribute.IntValue(1),
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range call.Attributes() {
+		attrs[attr.Key] = attr.Value
+	}
+	for key, value := range expected {
+		if attrs[key] != value {
+			t.Errorf("expected %s to be %s, got %s", key, value.Emit(), attrs[key].Emit())
+		}
+	}
+}
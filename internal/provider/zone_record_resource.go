@@ -6,17 +6,26 @@ package provider
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 )
 
 // Ensure the implementation satisfies the expected interfaces.
 var (
-	_ resource.Resource              = &zoneRecordResource{}
-	_ resource.ResourceWithConfigure = &zoneRecordResource{}
+	_ resource.Resource                = &zoneRecordResource{}
+	_ resource.ResourceWithConfigure   = &zoneRecordResource{}
+	_ resource.ResourceWithImportState = &zoneRecordResource{}
+	_ resource.ResourceWithModifyPlan  = &zoneRecordResource{}
 )
 
 // NewZoneRecordResource is a helper function to simplify the provider implementation.
@@ -26,7 +35,7 @@ func NewZoneRecordResource() resource.Resource {
 
 // zoneRecordResource is the resource implementation.
 type zoneRecordResource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // ZoneRecordResourceModel maps the resource schema data.
@@ -34,6 +43,9 @@ type ZoneRecordResourceModel struct {
 	Domain    types.String `tfsdk:"domain"`
 	Subdomain types.String `tfsdk:"subdomain"`
 	Record    recordModel  `tfsdk:"record"`
+
+	CustomerNumber     types.String `tfsdk:"customer_number"`
+	DeletionProtection types.Bool   `tfsdk:"deletion_protection"`
 }
 
 type recordModel struct {
@@ -67,11 +79,225 @@ func recordModelFromClient(rec loopia.Record) recordModel {
 }
 
 // recordsMatch checks if a client record matches the planned record values.
+//
+// Type and value are compared in normalized form, since Loopia may rewrite
+// them when storing the record. TTL and priority are only compared when
+// they are known in the plan.
 func (r *zoneRecordResource) recordsMatch(apiRecord loopia.Record, planRecord recordModel) bool {
-	return apiRecord.Type == planRecord.Type.ValueString() &&
-		apiRecord.Value == planRecord.Value.ValueString() &&
-		apiRecord.TTL == int(planRecord.Ttl.ValueInt32()) &&
-		apiRecord.Priority == int(planRecord.Priority.ValueInt32())
+	if !strings.EqualFold(apiRecord.Type, planRecord.Type.ValueString()) {
+		return false
+	}
+	if normalizeRecordValue(apiRecord.Type, apiRecord.Value) != normalizeRecordValue(planRecord.Type.ValueString(), planRecord.Value.ValueString()) {
+		return false
+	}
+	if !planRecord.Ttl.IsUnknown() && !planRecord.Ttl.IsNull() && apiRecord.TTL != int(planRecord.Ttl.ValueInt32()) {
+		return false
+	}
+	if !planRecord.Priority.IsUnknown() && !planRecord.Priority.IsNull() && apiRecord.Priority != int(planRecord.Priority.ValueInt32()) {
+		return false
+	}
+	return true
+}
+
+// normalizeRecordValue returns the record value in a canonical form so that
+// values which Loopia considers equal also compare equal here.
+func normalizeRecordValue(recordType, value string) string {
+	value = strings.TrimSpace(value)
+
+	switch strings.ToUpper(recordType) {
+	case "TXT", "SPF":
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+		}
+		return value
+	case "CNAME", "MX", "NS", "PTR", "SRV", "ALIAS":
+		// Host names are case-insensitive and may or may not be fully qualified.
+		return strings.ToLower(strings.TrimSuffix(value, "."))
+	case "AAAA":
+		return strings.ToLower(value)
+	default:
+		return value
+	}
+}
+
+// recordModelFromClientWithPrior converts a Loopia API record to the Terraform
+// model, keeping the prior type and value when they only differ from the
+// API's representation by normalization. This avoids spurious diffs.
+func recordModelFromClientWithPrior(rec loopia.Record, prior recordModel) recordModel {
+	model := recordModelFromClient(rec)
+	if strings.EqualFold(rec.Type, prior.Type.ValueString()) {
+		model.Type = prior.Type
+		if normalizeRecordValue(rec.Type, rec.Value) == normalizeRecordValue(prior.Type.ValueString(), prior.Value.ValueString()) {
+			model.Value = prior.Value
+		}
+	}
+	return model
+}
+
+// recordUnchanged reports whether the plan leaves the record in Loopia as
+// it is. Unknown TTL and priority values are left to Loopia, and so do not
+// count as changes.
+func recordUnchanged(state, plan ZoneRecordResourceModel) bool {
+	knownEqual := func(planned, prior types.Int32) bool {
+		return planned.IsUnknown() || planned.Equal(prior)
+	}
+
+	return plan.Domain.Equal(state.Domain) &&
+		plan.Subdomain.Equal(state.Subdomain) &&
+		plan.CustomerNumber.Equal(state.CustomerNumber) &&
+		plan.Record.Type.Equal(state.Record.Type) &&
+		plan.Record.Value.Equal(state.Record.Value) &&
+		knownEqual(plan.Record.Ttl, state.Record.Ttl) &&
+		knownEqual(plan.Record.Priority, state.Record.Priority)
+}
+
+// isCNAME reports whether the record type is CNAME.
+func isCNAME(recordType string) bool {
+	return strings.EqualFold(recordType, "CNAME")
+}
+
+// cnameConflict returns an existing record that a new record of the given
+// type could not be added next to, or nil if there is none. A CNAME record
+// cannot share its name with any other record.
+func cnameConflict(existing []loopia.Record, recordType string) *loopia.Record {
+	for i := range existing {
+		if isCNAME(recordType) || isCNAME(existing[i].Type) {
+			return &existing[i]
+		}
+	}
+	return nil
+}
+
+// cnameConflictDiagnostic returns the error for a record that cannot be added
+// next to the conflicting record.
+func cnameConflictDiagnostic(domain, subdomain string, conflict *loopia.Record) diag.Diagnostic {
+	return diag.NewAttributeErrorDiagnostic(
+		path.Root("record").AtName("type"),
+		"Conflicting CNAME Record",
+		fmt.Sprintf("A CNAME record cannot share its name with any other record, and %s/%s already has the %s record %d. "+
+			"Delete the conflicting record first, or change its type instead if Terraform manages it.",
+			domain, subdomain, conflict.Type, conflict.ID),
+	)
+}
+
+// findRecordByID returns the record with the given ID, or nil if there is none.
+func findRecordByID(records []loopia.Record, id int64) *loopia.Record {
+	for i := range records {
+		if records[i].ID == id {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// recordIds formats the IDs of the given records as a comma separated list.
+func recordIds(records []loopia.Record) string {
+	if len(records) == 0 {
+		return "none"
+	}
+	ids := make([]string, 0, len(records))
+	for _, rec := range records {
+		ids = append(ids, strconv.FormatInt(rec.ID, 10))
+	}
+	return strings.Join(ids, ", ")
+}
+
+// addRecord adds the planned record to the zone and identifies it among the
+// records that are not in existing, which the caller lists beforehand while
+// holding the zone lock.
+func (r *zoneRecordResource) addRecord(ctx context.Context, domain, subdomain string, planned recordModel, existing []loopia.Record) (*loopia.Record, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	existingIds := make(map[int64]struct{}, len(existing))
+	for _, rec := range existing {
+		existingIds[rec.ID] = struct{}{}
+	}
+
+	// Create the record using the API
+	planRecord := planned.toClientRecord()
+	planRecord.ID = 0
+	if err := r.client.AddZoneRecord(ctx, domain, subdomain, planRecord); err != nil {
+		diags.Append(apiErrorDiagnostic(
+			"Error Creating Zone Record",
+			"Could not create zone record",
+			err,
+		))
+		return nil, diags
+	}
+
+	// Fetch all records to find the newly created one with its ID
+	records, err := r.client.GetZoneRecords(ctx, domain, subdomain)
+	if err != nil {
+		diags.Append(apiErrorDiagnostic(
+			"Error Fetching Zone Records After Creation",
+			fmt.Sprintf("The zone record was created but could not be identified. "+
+				"Import it with: terraform import <address> %s/%s/%s/%s\n\nCould not list zone records",
+				domain, subdomain, planRecord.Type, planRecord.Value),
+			err,
+		))
+		return nil, diags
+	}
+
+	// Only consider records that did not exist before the add
+	var added, candidates []loopia.Record
+	for _, rec := range records {
+		if _, ok := existingIds[rec.ID]; ok {
+			continue
+		}
+		added = append(added, rec)
+		if r.recordsMatch(rec, planned) {
+			candidates = append(candidates, rec)
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		return &candidates[0], nil
+	case len(candidates) == 0 && len(added) == 1 && strings.EqualFold(added[0].Type, planRecord.Type):
+		// Loopia rewrote the value beyond what normalization accounts for,
+		// but this is the only record that appeared. Terraform requires the
+		// planned value after apply, so keep it and let the next refresh
+		// show the stored one.
+		created := added[0]
+		created.Value = planRecord.Value
+		diags.AddAttributeWarning(
+			path.Root("record").AtName("value"),
+			"Zone Record Value Rewritten by Loopia",
+			fmt.Sprintf("Loopia stored zone record ID %d in %s/%s with the value %q rather than %q. "+
+				"The next plan shows the difference.", created.ID, domain, subdomain, added[0].Value, planRecord.Value),
+		)
+		return &created, diags
+	case len(candidates) > 1:
+		diags.AddError(
+			"Unable to Identify Created Record",
+			fmt.Sprintf("The zone record was created, but %d matching records appeared in %s/%s at the same time (record IDs %s). "+
+				"Import the correct record by ID with: terraform import <address> %s/%s/<record_id>",
+				len(candidates), domain, subdomain, recordIds(candidates), domain, subdomain),
+		)
+		return nil, diags
+	default:
+		diags.AddError(
+			"Unable to Identify Created Record",
+			fmt.Sprintf("The zone record was reported as created, but no matching new record was found in %s/%s "+
+				"(new record IDs: %s). Verify the zone in the Loopia control panel.",
+				domain, subdomain, recordIds(added)),
+		)
+		return nil, diags
+	}
+}
+
+// subdomainExists reports whether the subdomain is still present on the domain.
+func (r *zoneRecordResource) subdomainExists(ctx context.Context, domain, subdomain string) (bool, error) {
+	subdomains, err := r.client.GetSubdomains(ctx, domain)
+	if err != nil {
+		return false, err
+	}
+	for _, s := range subdomains {
+		if s.Name == subdomain {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // Metadata returns the resource type name.
@@ -84,20 +310,39 @@ func (r *zoneRecordResource) Schema(_ context.Context, _ resource.SchemaRequest,
 	resp.Schema = schema.Schema{
 		Description: "Manages a DNS zone record in Loopia.",
 		Attributes: map[string]schema.Attribute{
+			// Loopia record IDs are only meaningful within their zone, so a
+			// record moves to another domain or subdomain by replacement.
 			"domain": schema.StringAttribute{
 				Required:    true,
-				Description: "The domain name to create records for.",
+				Description: "The domain name to create records for. Changing this replaces the record.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
 			},
 			"subdomain": schema.StringAttribute{
 				Required:    true,
-				Description: "The subdomain to create records for.",
+				Description: "The subdomain to create records for. Changing this replaces the record.",
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"customer_number": schema.StringAttribute{
+				Description: "The Loopia customer number to manage the record on behalf of. Only applies to reseller accounts. Defaults to the provider customer_number. Changing this replaces the record.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"deletion_protection": schema.BoolAttribute{
+				Description: "Refuse to delete or replace the record while set. Defaults to false.",
+				Optional:    true,
 			},
 			"record": schema.SingleNestedAttribute{
 				Description: "The DNS record to manage.",
 				Required:    true,
 				Attributes: map[string]schema.Attribute{
 					"type": schema.StringAttribute{
-						Description: "The type of the record (e.g., 'A', 'CNAME', 'MX').",
+						Description: "The type of the record (e.g., 'A', 'CNAME', 'MX'). Changing this replaces the record, except to or from CNAME, which removes the old record and adds the new one in a single update.",
 						Required:    true,
 					},
 					"value": schema.StringAttribute{
@@ -126,6 +371,11 @@ func (r *zoneRecordResource) Schema(_ context.Context, _ resource.SchemaRequest,
 
 // Create creates the resource and sets the initial Terraform state.
 func (r *zoneRecordResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_zone_record", "created"))
+		return
+	}
+
 	var plan ZoneRecordResourceModel
 
 	diags := req.Plan.Get(ctx, &plan)
@@ -134,53 +384,45 @@ func (r *zoneRecordResource) Create(ctx context.Context, req resource.CreateRequ
 		return
 	}
 
-	// Create the record using the API
-	planRecord := plan.Record.toClientRecord()
-	err := r.client.AddZoneRecord(
-		plan.Domain.ValueString(),
-		plan.Subdomain.ValueString(),
-		&planRecord,
-	)
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error Creating Zone Record",
-			fmt.Sprintf("Could not create zone record: %s", err.Error()),
-		)
-		return
-	}
+	ctx = withCustomerNumber(ctx, plan.CustomerNumber.ValueString())
 
-	// Fetch all records to find the newly created one with its ID
-	records, err := r.client.GetZoneRecords(
-		plan.Domain.ValueString(),
-		plan.Subdomain.ValueString(),
-	)
+	ctx, span := startOperation(ctx, "loopia_zone_record", "Create", zoneSpanAttributes(plan.Domain.ValueString(), plan.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	domain := plan.Domain.ValueString()
+	subdomain := plan.Subdomain.ValueString()
+
+	// Hold the zone lock across the add-then-list sequence so parallel
+	// creates in the same zone cannot pick up each other's records.
+	unlock := r.client.lockZone(domain, subdomain)
+	defer unlock()
+
+	// Snapshot the existing record IDs so the created record can be
+	// identified afterwards, even if an identical record already exists.
+	existing, err := r.client.GetZoneRecords(ctx, domain, subdomain)
 	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error Fetching Zone Records After Creation",
-			fmt.Sprintf("Could not list zone records: %s", err.Error()),
-		)
+		resp.Diagnostics.Append(apiErrorDiagnostic(
+			"Error Fetching Zone Records Before Creation",
+			"Could not list zone records",
+			err,
+		))
 		return
 	}
-
-	// Find the matching record
-	var createdRecord *loopia.Record
-	for _, rec := range records {
-		if r.recordsMatch(rec, plan.Record) {
-			createdRecord = &rec
-			break
-		}
+	// Check CNAME exclusivity up front, so that a conflict fails with an
+	// actionable error.
+	if conflict := cnameConflict(existing, plan.Record.Type.ValueString()); conflict != nil {
+		resp.Diagnostics.Append(cnameConflictDiagnostic(domain, subdomain, conflict))
+		return
 	}
 
-	if createdRecord == nil {
-		resp.Diagnostics.AddError(
-			"Unable to Identify Created Record",
-			"Could not find the newly created record in the API response.",
-		)
+	createdRecord, diags := r.addRecord(ctx, domain, subdomain, plan.Record, existing)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
 
 	// Update plan with the record including its ID
-	plan.Record = recordModelFromClient(*createdRecord)
+	plan.Record = recordModelFromClientWithPrior(*createdRecord, plan.Record)
 
 	// Save state
 	diags = resp.State.Set(ctx, &plan)
@@ -197,23 +439,61 @@ func (r *zoneRecordResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	// Fetch the record from the API
-	rec, err := r.client.GetZoneRecord(
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_zone_record", "Read", zoneSpanAttributes(state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	// Fetch all records of the zone rather than using GetZoneRecord, so
+	// that a missing record can be told apart from a failing API call.
+	// Loopia answers UNKNOWN_ERROR when the subdomain is gone, so that
+	// answer is only retried once the subdomain is known to exist.
+	records, err := r.client.GetZoneRecords(
+		withoutUnknownErrorRetry(ctx),
 		state.Domain.ValueString(),
 		state.Subdomain.ValueString(),
-		int64(state.Record.RecordId.ValueInt32()),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		// Only drop the resource if the subdomain is confirmed to be
+		// missing.
+		exists, existsErr := r.subdomainExists(ctx, state.Domain.ValueString(), state.Subdomain.ValueString())
+		if existsErr == nil && !exists {
+			tflog.Warn(ctx, "Subdomain no longer exists, removing zone record from state", map[string]any{
+				"domain":    state.Domain.ValueString(),
+				"subdomain": state.Subdomain.ValueString(),
+				"record_id": state.Record.RecordId.ValueInt32(),
+			})
+			resp.State.RemoveResource(ctx)
+			return
+		}
+
+		if isUnknownError(err) {
+			records, err = r.client.GetZoneRecords(ctx, state.Domain.ValueString(), state.Subdomain.ValueString())
+		}
+	}
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error Reading Zone Record",
-			fmt.Sprintf("Could not read zone record ID %d: %s",
-				state.Record.RecordId.ValueInt32(), err.Error()),
-		)
+			fmt.Sprintf("Could not read zone record ID %d", state.Record.RecordId.ValueInt32()),
+			err,
+		))
+		return
+	}
+
+	rec := findRecordByID(records, int64(state.Record.RecordId.ValueInt32()))
+	if rec == nil {
+		// Record no longer exists, remove resource from state
+		tflog.Warn(ctx, "Zone record no longer exists, removing from state", map[string]any{
+			"domain":    state.Domain.ValueString(),
+			"subdomain": state.Subdomain.ValueString(),
+			"record_id": state.Record.RecordId.ValueInt32(),
+		})
+		resp.State.RemoveResource(ctx)
 		return
 	}
 
 	// Update state with fresh data
-	state.Record = recordModelFromClient(*rec)
+	state.Record = recordModelFromClientWithPrior(*rec, state.Record)
 
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
@@ -221,6 +501,11 @@ func (r *zoneRecordResource) Read(ctx context.Context, req resource.ReadRequest,
 
 // Update updates the resource and sets the updated Terraform state on success.
 func (r *zoneRecordResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_zone_record", "updated"))
+		return
+	}
+
 	var plan, state ZoneRecordResourceModel
 
 	// Get current state to retrieve the record ID
@@ -240,37 +525,148 @@ func (r *zoneRecordResource) Update(ctx context.Context, req resource.UpdateRequ
 	// Preserve the record ID from state for the update
 	plan.Record.RecordId = state.Record.RecordId
 
+	// Only attributes that are never sent to Loopia, such as
+	// deletion_protection, changed.
+	if recordUnchanged(state, plan) {
+		plan.Record = state.Record
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	ctx = withCustomerNumber(ctx, plan.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_zone_record", "Update", zoneSpanAttributes(plan.Domain.ValueString(), plan.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	unlock := r.client.lockZone(plan.Domain.ValueString(), plan.Subdomain.ValueString())
+	defer unlock()
+
+	// Changing the type or value loses the old record.
+	if !plan.Record.Type.Equal(state.Record.Type) || !plan.Record.Value.Equal(state.Record.Value) {
+		if err := r.client.snapshotZone(ctx, plan.Domain.ValueString(), plan.Subdomain.ValueString(), "update loopia_zone_record"); err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostic(
+				"Unable to Snapshot Loopia Zone",
+				"Could not save the zone records to backup_dir before updating the record, so it was not updated",
+				err,
+			))
+			return
+		}
+	}
+
+	// ModifyPlan only keeps type changes that involve a CNAME in place.
+	if !strings.EqualFold(plan.Record.Type.ValueString(), state.Record.Type.ValueString()) {
+		r.replaceCNAME(ctx, state, &plan, resp)
+		return
+	}
+
 	// Update the record via API
 	rec := plan.Record.toClientRecord()
-	_, err := r.client.UpdateZoneRecord(
+	err := r.client.UpdateZoneRecord(
+		withPriorRecord(ctx, state.Record.toClientRecord()),
 		plan.Domain.ValueString(),
 		plan.Subdomain.ValueString(),
 		rec,
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error Updating Zone Record",
-			fmt.Sprintf("Could not update zone record ID %d: %s",
-				plan.Record.RecordId.ValueInt32(), err.Error()),
-		)
+			fmt.Sprintf("Could not update zone record ID %d", plan.Record.RecordId.ValueInt32()),
+			err,
+		))
 		return
 	}
 
 	// Refresh the state with updated data
 	updatedRec, err := r.client.GetZoneRecord(
+		ctx,
 		plan.Domain.ValueString(),
 		plan.Subdomain.ValueString(),
 		int64(plan.Record.RecordId.ValueInt32()),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error Refreshing Zone Record After Update",
-			fmt.Sprintf("Could not read updated zone record: %s", err.Error()),
+			"Could not read updated zone record",
+			err,
+		))
+		return
+	}
+
+	plan.Record = recordModelFromClientWithPrior(*updatedRec, plan.Record)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// replaceCNAME changes the type of a record to or from CNAME. Since a CNAME
+// record cannot share its name with any other record, the old record is
+// removed before the new one is added, whatever the lifecycle of the
+// resource. The caller must hold the zone lock.
+func (r *zoneRecordResource) replaceCNAME(ctx context.Context, state ZoneRecordResourceModel, plan *ZoneRecordResourceModel, resp *resource.UpdateResponse) {
+	domain := plan.Domain.ValueString()
+	subdomain := plan.Subdomain.ValueString()
+	oldID := int64(state.Record.RecordId.ValueInt32())
+
+	resp.Diagnostics.Append(r.client.checkDeletionProtection(
+		"loopia_zone_record",
+		state.DeletionProtection.ValueBool(),
+		domain,
+		subdomain,
+		state.Record.Type.ValueString(),
+	)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	existing, err := r.client.GetZoneRecords(ctx, domain, subdomain)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostic(
+			"Error Fetching Zone Records Before Update",
+			"Could not list zone records",
+			err,
+		))
+		return
+	}
+
+	// Fail before anything is removed if the new record would conflict with
+	// a record other than the one it replaces.
+	var others []loopia.Record
+	for _, rec := range existing {
+		if rec.ID != oldID {
+			others = append(others, rec)
+		}
+	}
+	if conflict := cnameConflict(others, plan.Record.Type.ValueString()); conflict != nil {
+		resp.Diagnostics.Append(cnameConflictDiagnostic(domain, subdomain, conflict))
+		return
+	}
+
+	err = r.client.RemoveZoneRecord(withPriorRecord(ctx, state.Record.toClientRecord()), domain, subdomain, oldID)
+	if err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostic(
+			"Error Updating Zone Record",
+			fmt.Sprintf("Could not remove zone record ID %d before adding its replacement", oldID),
+			err,
+		))
+		return
+	}
+
+	createdRecord, diags := r.addRecord(ctx, domain, subdomain, plan.Record, existing)
+	resp.Diagnostics.Append(diags...)
+	if diags.HasError() {
+		// The old record is gone, so the next plan creates the record again.
+		resp.State.RemoveResource(ctx)
+		resp.Diagnostics.AddError(
+			"Zone Record Removed Without Replacement",
+			fmt.Sprintf("Zone record ID %d was removed from %s/%s to change its type to %s, but the new record "+
+				"could not be added. The record has been removed from the state, so that the next apply creates it.",
+				oldID, domain, subdomain, plan.Record.Type.ValueString()),
 		)
 		return
 	}
 
-	plan.Record = recordModelFromClient(*updatedRec)
+	plan.Record = recordModelFromClientWithPrior(*createdRecord, plan.Record)
 
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
@@ -278,6 +674,11 @@ func (r *zoneRecordResource) Update(ctx context.Context, req resource.UpdateRequ
 
 // Delete deletes the resource and removes the Terraform state on success.
 func (r *zoneRecordResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	if r.client.readOnly {
+		resp.Diagnostics.Append(readOnlyDiagnostic("loopia_zone_record", "deleted"))
+		return
+	}
+
 	var state ZoneRecordResourceModel
 
 	diags := req.State.Get(ctx, &state)
@@ -286,18 +687,47 @@ func (r *zoneRecordResource) Delete(ctx context.Context, req resource.DeleteRequ
 		return
 	}
 
+	resp.Diagnostics.Append(r.client.checkDeletionProtection(
+		"loopia_zone_record",
+		state.DeletionProtection.ValueBool(),
+		state.Domain.ValueString(),
+		state.Subdomain.ValueString(),
+		state.Record.Type.ValueString(),
+	)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_zone_record", "Delete", zoneSpanAttributes(state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
+	unlock := r.client.lockZone(state.Domain.ValueString(), state.Subdomain.ValueString())
+	defer unlock()
+
+	if err := r.client.snapshotZone(ctx, state.Domain.ValueString(), state.Subdomain.ValueString(), "delete loopia_zone_record"); err != nil {
+		resp.Diagnostics.Append(apiErrorDiagnostic(
+			"Unable to Snapshot Loopia Zone",
+			"Could not save the zone records to backup_dir before deleting the record, so it was not deleted",
+			err,
+		))
+		return
+	}
+
 	// Delete the record via API
-	_, err := r.client.RemoveZoneRecord(
+	err := r.client.RemoveZoneRecord(
+		withPriorRecord(ctx, state.Record.toClientRecord()),
 		state.Domain.ValueString(),
 		state.Subdomain.ValueString(),
 		int64(state.Record.RecordId.ValueInt32()),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Error Deleting Zone Record",
-			fmt.Sprintf("Could not delete zone record ID %d: %s",
-				state.Record.RecordId.ValueInt32(), err.Error()),
-		)
+			fmt.Sprintf("Could not delete zone record ID %d", state.Record.RecordId.ValueInt32()),
+			err,
+		))
 		return
 	}
 }
@@ -308,14 +738,147 @@ func (r *zoneRecordResource) Configure(_ context.Context, req resource.Configure
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Resource Configure Type",
-			fmt.Sprintf("Expected *loopia.API, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
 
 	r.client = client
 }
+
+// ModifyPlan rejects changes in domains the provider may not manage, and
+// replaces records whose type changes, except to or from CNAME.
+func (r *zoneRecordResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	modifyPlanDomainPolicy(ctx, r.client, req, resp)
+
+	// Creates and destroys have nothing to replace.
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	typePath := path.Root("record").AtName("type")
+
+	var priorType, plannedType types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, typePath, &priorType)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, typePath, &plannedType)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Loopia stores types in upper case, so only a different type is a
+	// change.
+	if !plannedType.IsUnknown() && strings.EqualFold(plannedType.ValueString(), priorType.ValueString()) {
+		return
+	}
+
+	// Updating the type in place is rejected by Loopia, so type changes
+	// normally replace the record, which create_before_destroy can do
+	// without leaving the name unresolved.
+	if plannedType.IsUnknown() || !(isCNAME(priorType.ValueString()) || isCNAME(plannedType.ValueString())) {
+		resp.RequiresReplace = append(resp.RequiresReplace, typePath)
+		return
+	}
+
+	// A CNAME record cannot share its name with the record it replaces, so
+	// the old record must be removed first. Terraform would not do that with
+	// create_before_destroy, so Update removes and adds the record instead,
+	// unless the record moves to another zone and is replaced anyway.
+	var priorZone, plannedZone [2]types.String
+	for i, name := range []string{"domain", "subdomain"} {
+		resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root(name), &priorZone[i])...)
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(name), &plannedZone[i])...)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if !plannedZone[0].Equal(priorZone[0]) || !plannedZone[1].Equal(priorZone[1]) {
+		resp.RequiresReplace = append(resp.RequiresReplace, typePath)
+	}
+}
+
+// ImportState imports an existing zone record into Terraform.
+//
+// Two ID formats are accepted:
+//   - domain/subdomain/record_id, e.g. example.com/www/123456
+//   - domain/subdomain/type/value, e.g. example.com/www/A/1.2.3.4
+//
+// The second form looks the record up through getZoneRecords and requires
+// exactly one record with the given type and value to exist. Values are
+// compared in normalized form, as in Create. Either form may be prefixed
+// with a reseller customer number, e.g. C12345:example.com/www/123456.
+func (r *zoneRecordResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	customerNumber, id := splitImportCustomerNumber(req.ID)
+	parts := strings.SplitN(id, "/", 4)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected import identifier with format: [customer_number:]domain/subdomain/record_id or "+
+				"[customer_number:]domain/subdomain/type/value. Got: %q", req.ID),
+		)
+		return
+	}
+
+	domain, subdomain := parts[0], parts[1]
+	ctx = withCustomerNumber(ctx, customerNumber)
+
+	var recordId int64
+	if len(parts) == 3 {
+		id, err := strconv.ParseInt(parts[2], 10, 32)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Unexpected Import Identifier",
+				fmt.Sprintf("Expected a numeric record ID in import identifier %q: %s", req.ID, err.Error()),
+			)
+			return
+		}
+		recordId = id
+	} else {
+		recordType, value := parts[2], parts[3]
+
+		records, err := r.client.GetZoneRecords(ctx, domain, subdomain)
+		if err != nil {
+			resp.Diagnostics.Append(apiErrorDiagnostic(
+				"Error Importing Zone Record",
+				"Could not list zone records",
+				err,
+			))
+			return
+		}
+
+		var matches []loopia.Record
+		for _, rec := range records {
+			if strings.EqualFold(rec.Type, recordType) && normalizeRecordValue(rec.Type, rec.Value) == normalizeRecordValue(recordType, value) {
+				matches = append(matches, rec)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			resp.Diagnostics.AddError(
+				"Zone Record Not Found",
+				fmt.Sprintf("No %s record with value %q exists for %s/%s.", recordType, value, domain, subdomain),
+			)
+			return
+		case 1:
+			recordId = matches[0].ID
+		default:
+			resp.Diagnostics.AddError(
+				"Ambiguous Import Identifier",
+				fmt.Sprintf("Found %d %s records with value %q for %s/%s. Import the record by ID instead.",
+					len(matches), recordType, value, domain, subdomain),
+			)
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("domain"), domain)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("subdomain"), subdomain)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("record").AtName("record_id"), int32(recordId))...)
+	if customerNumber != "" {
+		resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("customer_number"), customerNumber)...)
+	}
+}
@@ -0,0 +1,579 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diskoteket/loopia-go"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+func TestNormalizeRecordValue(t *testing.T) {
+	tests := []struct {
+		recordType string
+		a, b       string
+	}{
+		{"CNAME", "target.example.com.", "Target.Example.com"},
+		{"MX", "mail.example.com", "mail.example.com."},
+		{"TXT", `"v=spf1 -all"`, "v=spf1 -all"},
+		{"AAAA", "2001:DB8::1", "2001:db8::1"},
+		{"A", " 192.0.2.1", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		if got, want := normalizeRecordValue(tt.recordType, tt.a), normalizeRecordValue(tt.recordType, tt.b); got != want {
+			t.Errorf("normalizeRecordValue(%q): %q != %q", tt.recordType, got, want)
+		}
+	}
+
+	if normalizeRecordValue("TXT", "Hello") == normalizeRecordValue("TXT", "hello") {
+		t.Error("TXT values must be compared case-sensitively")
+	}
+}
+
+func TestRecordUnchanged(t *testing.T) {
+	state := ZoneRecordResourceModel{
+		Domain:    types.StringValue("example.com"),
+		Subdomain: types.StringValue("@"),
+		Record: recordModel{
+			Type:     types.StringValue("MX"),
+			Value:    types.StringValue("mail.example.com"),
+			Ttl:      types.Int32Value(3600),
+			Priority: types.Int32Value(10),
+			RecordId: types.Int32Value(1),
+		},
+		DeletionProtection: types.BoolNull(),
+	}
+
+	plan := state
+	plan.DeletionProtection = types.BoolValue(true)
+	plan.Record.Ttl = types.Int32Unknown()
+	if !recordUnchanged(state, plan) {
+		t.Error("expected a deletion_protection change to leave the record unchanged")
+	}
+
+	plan.Record.Priority = types.Int32Value(20)
+	if recordUnchanged(state, plan) {
+		t.Error("expected a priority change to change the record")
+	}
+
+	plan = state
+	plan.Record.Value = types.StringValue("mx.example.com")
+	if recordUnchanged(state, plan) {
+		t.Error("expected a value change to change the record")
+	}
+}
+
+// testRequiresReplace checks that changing any of the given string
+// attributes of an existing resource requires its replacement.
+func testRequiresReplace(t *testing.T, r resource.Resource, names ...string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			attribute, ok := schemaResp.Schema.Attributes[name].(schema.StringAttribute)
+			if !ok {
+				t.Fatalf("unexpected %s attribute: %T", name, schemaResp.Schema.Attributes[name])
+			}
+
+			value := func(v string) tftypes.Value {
+				return testResourceValue(t, r, map[string]tftypes.Value{name: tftypes.NewValue(tftypes.String, v)})
+			}
+
+			req := planmodifier.StringRequest{
+				Path:       path.Root(name),
+				StateValue: types.StringValue("old"),
+				PlanValue:  types.StringValue("new"),
+				State:      tfsdk.State{Schema: schemaResp.Schema, Raw: value("old")},
+				Plan:       tfsdk.Plan{Schema: schemaResp.Schema, Raw: value("new")},
+			}
+			resp := &planmodifier.StringResponse{PlanValue: req.PlanValue}
+			for _, modifier := range attribute.PlanModifiers {
+				modifier.PlanModifyString(ctx, req, resp)
+			}
+
+			if !resp.RequiresReplace {
+				t.Errorf("expected a %s change to require replacement", name)
+			}
+		})
+	}
+}
+
+func TestZoneRecordResourceRequiresReplace(t *testing.T) {
+	testRequiresReplace(t, &zoneRecordResource{}, "domain", "subdomain", "customer_number")
+}
+
+func TestZoneRecordResourceModifyPlanTypeChange(t *testing.T) {
+	r := &zoneRecordResource{}
+
+	value := func(subdomain string, recordType any) map[string]tftypes.Value {
+		return testZoneRecordValues(t, subdomain, recordType, "192.0.2.1", nil)
+	}
+
+	testCases := map[string]struct {
+		state, plan   map[string]tftypes.Value
+		expectReplace bool
+	}{
+		"create": {
+			plan: value("www", "A"),
+		},
+		"destroy": {
+			state: value("www", "A"),
+		},
+		"unchanged": {
+			state: value("www", "A"),
+			plan:  value("www", "A"),
+		},
+		"case-only": {
+			state: value("www", "A"),
+			plan:  value("www", "a"),
+		},
+		"type-change": {
+			state:         value("www", "A"),
+			plan:          value("www", "AAAA"),
+			expectReplace: true,
+		},
+		"to-cname": {
+			state: value("www", "A"),
+			plan:  value("www", "CNAME"),
+		},
+		"from-cname": {
+			state: value("www", "cname"),
+			plan:  value("www", "TXT"),
+		},
+		"to-cname-in-another-zone": {
+			state:         value("www", "A"),
+			plan:          value("mail", "CNAME"),
+			expectReplace: true,
+		},
+		"unknown": {
+			state:         value("www", "A"),
+			plan:          value("www", tftypes.UnknownValue),
+			expectReplace: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			resp := testModifyPlan(t, r, testCase.state, testCase.plan)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+
+			replace := false
+			for _, p := range resp.RequiresReplace {
+				if p.Equal(path.Root("record").AtName("type")) {
+					replace = true
+				}
+			}
+			if replace != testCase.expectReplace {
+				t.Errorf("expected replacement %t, got %v", testCase.expectReplace, resp.RequiresReplace)
+			}
+		})
+	}
+}
+
+func TestCNAMEConflict(t *testing.T) {
+	existing := []loopia.Record{
+		{ID: 1, Type: "A", Value: "192.0.2.1"},
+		{ID: 2, Type: "TXT", Value: "v=spf1 -all"},
+	}
+
+	if conflict := cnameConflict(existing, "AAAA"); conflict != nil {
+		t.Errorf("unexpected conflict with %+v", conflict)
+	}
+	if conflict := cnameConflict(existing, "cname"); conflict == nil || conflict.ID != 1 {
+		t.Errorf("expected a conflict with record 1, got %+v", conflict)
+	}
+	if conflict := cnameConflict([]loopia.Record{{ID: 3, Type: "CNAME"}}, "A"); conflict == nil || conflict.ID != 3 {
+		t.Errorf("expected a conflict with record 3, got %+v", conflict)
+	}
+	if conflict := cnameConflict(nil, "CNAME"); conflict != nil {
+		t.Errorf("unexpected conflict with %+v", conflict)
+	}
+}
+
+// testZoneRecordValues returns the attribute values of a loopia_zone_record
+// in example.com. The record type and ID may be tftypes.UnknownValue or nil.
+func testZoneRecordValues(t *testing.T, subdomain string, recordType any, value string, recordID any) map[string]tftypes.Value {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	(&zoneRecordResource{}).Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	objectType := schemaResp.Schema.Attributes["record"].GetType().TerraformType(ctx).(tftypes.Object)
+
+	record := make(map[string]tftypes.Value, len(objectType.AttributeTypes))
+	for name, attributeType := range objectType.AttributeTypes {
+		record[name] = tftypes.NewValue(attributeType, nil)
+	}
+	record["type"] = tftypes.NewValue(tftypes.String, recordType)
+	record["value"] = tftypes.NewValue(tftypes.String, value)
+	if recordID != nil {
+		record["record_id"] = tftypes.NewValue(tftypes.Number, recordID)
+	}
+
+	return map[string]tftypes.Value{
+		"domain":    tftypes.NewValue(tftypes.String, "example.com"),
+		"subdomain": tftypes.NewValue(tftypes.String, subdomain),
+		"record":    tftypes.NewValue(objectType, record),
+	}
+}
+
+// testRead runs Read of the resource with the given prior state.
+func testRead(t *testing.T, r resource.Resource, state map[string]tftypes.Value) *resource.ReadResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	req := resource.ReadRequest{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, state)},
+	}
+	resp := &resource.ReadResponse{State: req.State}
+	r.Read(ctx, req, resp)
+	return resp
+}
+
+// testImportState runs ImportState of the resource with the given ID.
+func testImportState(t *testing.T, r resource.ResourceWithImportState, id string) *resource.ImportStateResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	resp := &resource.ImportStateResponse{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, nil)},
+	}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: id}, resp)
+	return resp
+}
+
+func TestSplitImportCustomerNumber(t *testing.T) {
+	tests := map[string][2]string{
+		"example.com/www/123":                   {"", "example.com/www/123"},
+		"C123:example.com/www/123":              {"C123", "example.com/www/123"},
+		"example.com/www/AAAA/2001:db8::1":      {"", "example.com/www/AAAA/2001:db8::1"},
+		"C123:example.com/www/AAAA/2001:db8::1": {"C123", "example.com/www/AAAA/2001:db8::1"},
+	}
+
+	for id, want := range tests {
+		customerNumber, rest := splitImportCustomerNumber(id)
+		if customerNumber != want[0] || rest != want[1] {
+			t.Errorf("splitImportCustomerNumber(%q) = %q, %q, want %q, %q", id, customerNumber, rest, want[0], want[1])
+		}
+	}
+}
+
+func TestZoneRecordResourceImportStateByContent(t *testing.T) {
+	f, client := newFakeLoopia(t)
+	f.addRecord("example.com/www", loopia.Record{Type: "A", TTL: 3600, Value: "192.0.2.1"})
+	id := f.addRecord("example.com/www", loopia.Record{Type: "CNAME", TTL: 3600, Value: "target.example.com."})
+
+	r := &zoneRecordResource{client: client}
+	resp := testImportState(t, r, "C123:example.com/www/cname/Target.example.com")
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+
+	var recordID types.Int32
+	var customerNumber types.String
+	resp.Diagnostics.Append(resp.State.GetAttribute(context.Background(), path.Root("record").AtName("record_id"), &recordID)...)
+	resp.Diagnostics.Append(resp.State.GetAttribute(context.Background(), path.Root("customer_number"), &customerNumber)...)
+	if resp.Diagnostics.HasError() {
+		t.Fatalf("unexpected error: %v", resp.Diagnostics)
+	}
+
+	if recordID.ValueInt32() != int32(id) {
+		t.Errorf("expected record ID %d, got %s", id, recordID)
+	}
+	if customerNumber.ValueString() != "C123" {
+		t.Errorf("expected customer number C123, got %s", customerNumber)
+	}
+	if len(f.customerNumbers) != 1 || f.customerNumbers[0] != "C123" {
+		t.Errorf("expected the records to be listed on behalf of C123, got %q", f.customerNumbers)
+	}
+}
+
+func TestZoneRecordResourceReadRemoved(t *testing.T) {
+	f, client := newFakeLoopia(t)
+	id := f.addRecord("example.com/www", loopia.Record{Type: "A", TTL: 3600, Value: "192.0.2.1"})
+	f.records["example.com/empty"] = []loopia.Record{}
+
+	client.retry = retryPolicy{maxRetries: 3, maxWait: time.Millisecond}
+
+	r := &zoneRecordResource{client: client}
+
+	testCases := map[string]struct {
+		subdomain     string
+		recordID      int64
+		expectRemoved bool
+		expectCalls   []string
+	}{
+		"exists": {
+			subdomain:   "www",
+			recordID:    id,
+			expectCalls: []string{"getZoneRecords"},
+		},
+		"record-deleted": {
+			subdomain:     "empty",
+			recordID:      id,
+			expectRemoved: true,
+			expectCalls:   []string{"getZoneRecords"},
+		},
+		// Loopia answers UNKNOWN_ERROR for the removed subdomain, which is
+		// not retried before the subdomain is checked.
+		"subdomain-deleted": {
+			subdomain:     "gone",
+			recordID:      id,
+			expectRemoved: true,
+			expectCalls:   []string{"getZoneRecords", "getSubdomains"},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f.calls = nil
+
+			resp := testRead(t, r, testZoneRecordValues(t, testCase.subdomain, "A", "192.0.2.1", testCase.recordID))
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+			if removed := resp.State.Raw.IsNull(); removed != testCase.expectRemoved {
+				t.Errorf("expected removed %t, got %t", testCase.expectRemoved, removed)
+			}
+			if strings.Join(f.calls, ",") != strings.Join(testCase.expectCalls, ",") {
+				t.Errorf("expected calls %v, got %v", testCase.expectCalls, f.calls)
+			}
+		})
+	}
+}
+
+func TestZoneRecordResourceReadAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value><string>AUTH_ERROR</string></value></param></params></methodResponse>`)
+	}))
+	defer server.Close()
+
+	client := newLoopiaClient(&loopia.API{Username: "user@loopiaapi", Password: "secret", RPCEndpoint: server.URL}, nil, retryPolicy{})
+	r := &zoneRecordResource{client: client}
+
+	resp := testRead(t, r, testZoneRecordValues(t, "www", "A", "192.0.2.1", 1))
+	if !resp.Diagnostics.HasError() {
+		t.Fatal("expected an error")
+	}
+	if resp.State.Raw.IsNull() {
+		t.Error("expected the record to be kept in state")
+	}
+}
+
+// testCreate runs Create of the resource with the given plan.
+func testCreate(t *testing.T, r resource.Resource, plan map[string]tftypes.Value) *resource.CreateResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	planValue := testResourceValue(t, r, plan)
+	req := resource.CreateRequest{
+		Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: planValue},
+		Plan:   tfsdk.Plan{Schema: schemaResp.Schema, Raw: planValue},
+	}
+	resp := &resource.CreateResponse{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, nil)},
+	}
+	r.Create(ctx, req, resp)
+	return resp
+}
+
+func TestZoneRecordResourceCreateIdentifiesRecord(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		existing []loopia.Record
+		store    func(loopia.Record) loopia.Record
+		afterAdd func(f *fakeLoopia, zone string)
+		// expectError is the expected error summary, if any.
+		expectError   string
+		expectWarning bool
+	}{
+		"new-id": {
+			existing: []loopia.Record{{Type: "A", TTL: 3600, Value: "192.0.2.1"}},
+			store: func(rec loopia.Record) loopia.Record {
+				rec.Value = strings.ToLower(rec.Value) + "."
+				return rec
+			},
+		},
+		"rewritten-value": {
+			store: func(rec loopia.Record) loopia.Record {
+				rec.Value = "mx.example.net."
+				return rec
+			},
+			expectWarning: true,
+		},
+		"identical-existing-record": {
+			existing: []loopia.Record{{Type: "MX", TTL: 3600, Value: "mail.example.com"}},
+		},
+		"ambiguous": {
+			afterAdd: func(f *fakeLoopia, zone string) {
+				f.addRecord(zone, loopia.Record{Type: "MX", TTL: 3600, Value: "mail.example.com"})
+			},
+			expectError: "Unable to Identify Created Record",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, client := newFakeLoopia(t)
+			f.records["example.com/www"] = []loopia.Record{}
+			existingIDs := make(map[int64]bool)
+			for _, rec := range testCase.existing {
+				existingIDs[f.addRecord("example.com/www", rec)] = true
+			}
+			f.store = testCase.store
+			f.afterAdd = testCase.afterAdd
+
+			r := &zoneRecordResource{client: client}
+			resp := testCreate(t, r, testZoneRecordValues(t, "www", "MX", "Mail.example.com", tftypes.UnknownValue))
+
+			if testCase.expectError != "" {
+				if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != testCase.expectError {
+					t.Fatalf("expected error %q, got: %v", testCase.expectError, resp.Diagnostics)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+			if warning := resp.Diagnostics.WarningsCount() > 0; warning != testCase.expectWarning {
+				t.Errorf("expected warning %t, got %v", testCase.expectWarning, resp.Diagnostics)
+			}
+
+			var recordID types.Int32
+			var value types.String
+			resp.Diagnostics.Append(resp.State.GetAttribute(ctx, path.Root("record").AtName("record_id"), &recordID)...)
+			resp.Diagnostics.Append(resp.State.GetAttribute(ctx, path.Root("record").AtName("value"), &value)...)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+
+			if existingIDs[int64(recordID.ValueInt32())] {
+				t.Errorf("expected the new record, got the existing record %s", recordID)
+			}
+			if got := findRecordByID(f.records["example.com/www"], int64(recordID.ValueInt32())); got == nil {
+				t.Errorf("record %s does not exist", recordID)
+			}
+			if value.ValueString() != "Mail.example.com" {
+				t.Errorf("expected the planned value to be kept, got %s", value)
+			}
+		})
+	}
+}
+
+// testUpdate runs Update of the resource from the given prior state to the
+// given plan.
+func testUpdate(t *testing.T, r resource.Resource, state, plan map[string]tftypes.Value) *resource.UpdateResponse {
+	t.Helper()
+
+	ctx := context.Background()
+
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+
+	planValue := testResourceValue(t, r, plan)
+	req := resource.UpdateRequest{
+		Config: tfsdk.Config{Schema: schemaResp.Schema, Raw: planValue},
+		Plan:   tfsdk.Plan{Schema: schemaResp.Schema, Raw: planValue},
+		State:  tfsdk.State{Schema: schemaResp.Schema, Raw: testResourceValue(t, r, state)},
+	}
+	resp := &resource.UpdateResponse{
+		State: tfsdk.State{Schema: schemaResp.Schema, Raw: planValue},
+	}
+	r.Update(ctx, req, resp)
+	return resp
+}
+
+func TestZoneRecordResourceUpdateCNAMEType(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := map[string]struct {
+		other *loopia.Record
+		// expectError is the expected error summary, if any.
+		expectError string
+	}{
+		"replaced": {},
+		"unrelated-record": {
+			other:       &loopia.Record{Type: "TXT", TTL: 3600, Value: "v=spf1 -all"},
+			expectError: "Conflicting CNAME Record",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, client := newFakeLoopia(t)
+			oldID := f.addRecord("example.com/www", loopia.Record{Type: "A", TTL: 3600, Value: "192.0.2.1"})
+			if testCase.other != nil {
+				f.addRecord("example.com/www", *testCase.other)
+			}
+
+			r := &zoneRecordResource{client: client}
+			resp := testUpdate(t, r,
+				testZoneRecordValues(t, "www", "A", "192.0.2.1", oldID),
+				testZoneRecordValues(t, "www", "CNAME", "example.net.", tftypes.UnknownValue),
+			)
+
+			if testCase.expectError != "" {
+				if !resp.Diagnostics.HasError() || resp.Diagnostics.Errors()[0].Summary() != testCase.expectError {
+					t.Fatalf("expected error %q, got: %v", testCase.expectError, resp.Diagnostics)
+				}
+				if findRecordByID(f.records["example.com/www"], oldID) == nil {
+					t.Errorf("expected record %d to be kept", oldID)
+				}
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+
+			var recordID types.Int32
+			resp.Diagnostics.Append(resp.State.GetAttribute(ctx, path.Root("record").AtName("record_id"), &recordID)...)
+			if resp.Diagnostics.HasError() {
+				t.Fatalf("unexpected error: %v", resp.Diagnostics)
+			}
+
+			records := f.records["example.com/www"]
+			if len(records) != 1 || records[0].Type != "CNAME" || records[0].ID != int64(recordID.ValueInt32()) {
+				t.Errorf("expected only the new CNAME record %s, got %+v", recordID, records)
+			}
+			if want := []string{"getZoneRecords", "removeZoneRecord", "addZoneRecord", "getZoneRecords"}; strings.Join(f.calls, ",") != strings.Join(want, ",") {
+				t.Errorf("expected calls %v, got %v", want, f.calls)
+			}
+		})
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/diskoteket/loopia-go"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -26,14 +25,15 @@ func NewZoneRecordsDataSource() datasource.DataSource {
 
 // zoneRecordsDataSource is the data source implementation.
 type zoneRecordsDataSource struct {
-	client *loopia.API
+	client *loopiaClient
 }
 
 // ZoneRecordsDataSourceModel maps the data source schema data.
 type ZoneRecordsDataSourceModel struct {
-	Domain      types.String       `tfsdk:"domain"`
-	Subdomain   types.String       `tfsdk:"subdomain"`
-	ZoneRecords []zoneRecordsModel `tfsdk:"zone_records"`
+	CustomerNumber types.String       `tfsdk:"customer_number"`
+	Domain         types.String       `tfsdk:"domain"`
+	Subdomain      types.String       `tfsdk:"subdomain"`
+	ZoneRecords    []zoneRecordsModel `tfsdk:"zone_records"`
 }
 
 type zoneRecordsModel struct {
@@ -54,6 +54,10 @@ func (d *zoneRecordsDataSource) Schema(_ context.Context, _ datasource.SchemaReq
 	resp.Schema = schema.Schema{
 		Description: "Fetches DNS zone records for a specific domain and subdomain from Loopia.",
 		Attributes: map[string]schema.Attribute{
+			"customer_number": schema.StringAttribute{
+				Optional:    true,
+				Description: "The Loopia customer number to read zone records for. Only applies to reseller accounts. Defaults to the provider customer_number.",
+			},
 			"domain": schema.StringAttribute{
 				Required:    true,
 				Description: "The domain name to retrieve records for.",
@@ -104,15 +108,22 @@ func (d *zoneRecordsDataSource) Read(ctx context.Context, req datasource.ReadReq
 		return
 	}
 
+	ctx = withCustomerNumber(ctx, state.CustomerNumber.ValueString())
+
+	ctx, span := startOperation(ctx, "loopia_zone_records", "Read", zoneSpanAttributes(state.Domain.ValueString(), state.Subdomain.ValueString())...)
+	defer endSpan(span, &resp.Diagnostics)
+
 	zoneRecords, err := d.client.GetZoneRecords(
+		ctx,
 		state.Domain.ValueString(),
 		state.Subdomain.ValueString(),
 	)
 	if err != nil {
-		resp.Diagnostics.AddError(
+		resp.Diagnostics.Append(apiErrorDiagnostic(
 			"Unable to Read Loopia Zone Records",
-			fmt.Sprintf("Could not read zone records: %s", err.Error()),
-		)
+			"Could not read zone records",
+			err,
+		))
 		return
 	}
 
@@ -139,11 +150,11 @@ func (d *zoneRecordsDataSource) Configure(_ context.Context, req datasource.Conf
 		return
 	}
 
-	client, ok := req.ProviderData.(*loopia.API)
+	client, ok := req.ProviderData.(*loopiaClient)
 	if !ok {
 		resp.Diagnostics.AddError(
 			"Unexpected Data Source Configure Type",
-			fmt.Sprintf("Expected *loopia.API, got: %T. Please report this issue to the provider developers.", req.ProviderData),
+			fmt.Sprintf("Expected *loopiaClient, got: %T. Please report this issue to the provider developers.", req.ProviderData),
 		)
 		return
 	}
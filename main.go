@@ -6,8 +6,10 @@ package main
 import (
 	"context"
 	"flag"
+	"io"
 	"log"
 
+	fwprovider "github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 	"github.com/hashicorp/terraform-provider-loopia/internal/provider"
 )
@@ -35,7 +37,28 @@ func main() {
 		Debug:   debug,
 	}
 
-	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+	ctx := context.Background()
+
+	// Spans are only exported when the OTEL_* environment variables enable
+	// it, and are flushed once Terraform shuts the provider down.
+	shutdownTracing, err := provider.StartTracing(ctx, version)
+	if err != nil {
+		log.Printf("[WARN] Unable to start OpenTelemetry tracing: %s", err)
+	}
+
+	loopiaProvider := provider.New(version)()
+	err = providerserver.Serve(ctx, func() fwprovider.Provider { return loopiaProvider }, opts)
+
+	// Terraform has stopped the provider, so the run is over.
+	if closer, ok := loopiaProvider.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil {
+			log.Printf("[WARN] %s", closeErr)
+		}
+	}
+
+	if shutdownErr := shutdownTracing(ctx); shutdownErr != nil {
+		log.Printf("[WARN] Unable to flush OpenTelemetry spans: %s", shutdownErr)
+	}
 
 	if err != nil {
 		log.Fatal(err.Error())